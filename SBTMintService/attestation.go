@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// attestationMaxLifetime - アテステーションの有効期間（exp - iat）の上限
+	attestationMaxLifetime = 10 * time.Minute
+	// attestationLeeway - 時刻のずれの許容範囲
+	attestationLeeway = time.Minute
+)
+
+var (
+	// errAttestationRequired - アテステーションが必須なのに含まれていない
+	errAttestationRequired = errors.New("verified ID attestation is required")
+	// errAttestationInvalid - 署名・形式・期限・発行者のいずれかが不正
+	errAttestationInvalid = errors.New("invalid verified ID attestation")
+)
+
+// AttestationClaims - C#バックエンドがVerified IDの提示を検証した後に署名する内容
+type AttestationClaims struct {
+	// Issuer - アテステーションを署名したサービス
+	Issuer         string `json:"iss"`
+	RequestID      string `json:"requestId"`
+	WalletAddress  string `json:"wallet"`
+	CredentialType string `json:"credentialType"`
+	// CredentialIssuer - 提示されたVerified IDの発行者（DID）
+	CredentialIssuer string `json:"credentialIssuer,omitempty"`
+	IssuedAt         int64  `json:"iat"`
+	ExpiresAt        int64  `json:"exp"`
+}
+
+// AttestationConfig - アテステーションの検証設定
+type AttestationConfig struct {
+	// JWKS - 署名鍵（JWK Set）のJSON、またはJSONファイルのパス
+	JWKS string
+	// Issuer - 許可する署名者（iss）
+	Issuer string
+	// CredentialTypes / CredentialIssuers - 許可するVerified IDの種類と発行者DID（空なら制限しない）
+	CredentialTypes   []string
+	CredentialIssuers []string
+	// Required - アテステーションの無いMintリクエストを拒否する
+	Required bool
+}
+
+// AttestationVerifier - ES256で署名されたJWS形式のアテステーションを検証する
+type AttestationVerifier struct {
+	cfg  AttestationConfig
+	keys map[string]*ecdsa.PublicKey // kid → 公開鍵
+}
+
+// jwk - P-256のECの公開鍵
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	Kid string `json:"kid"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// newAttestationVerifier - 設定の鍵を読み込む（Required で鍵が無い場合はエラー）
+func newAttestationVerifier(cfg AttestationConfig) (*AttestationVerifier, error) {
+	v := &AttestationVerifier{cfg: cfg, keys: make(map[string]*ecdsa.PublicKey)}
+
+	data := []byte(strings.TrimSpace(cfg.JWKS))
+	if len(data) > 0 && data[0] != '{' {
+		var err error
+		if data, err = os.ReadFile(cfg.JWKS); err != nil {
+			return nil, fmt.Errorf("failed to read attestation keys: %w", err)
+		}
+	}
+	if len(data) > 0 {
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		if err := json.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("invalid attestation keys: %w", err)
+		}
+		for _, key := range set.Keys {
+			pub, err := key.publicKey()
+			if err != nil {
+				return nil, fmt.Errorf("invalid attestation key %q: %w", key.Kid, err)
+			}
+			v.keys[key.Kid] = pub
+		}
+	}
+
+	if cfg.Required && len(v.keys) == 0 {
+		return nil, errors.New("MINT_ATTESTATION_REQUIRED is set but no attestation keys are configured")
+	}
+	return v, nil
+}
+
+// publicKey - JWKをECDSAの公開鍵に変換する
+func (k jwk) publicKey() (*ecdsa.PublicKey, error) {
+	if k.Kty != "EC" || k.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported key type %s/%s (only EC P-256 is supported)", k.Kty, k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil || len(x) != 32 {
+		return nil, errors.New("invalid x coordinate")
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil || len(y) != 32 {
+		return nil, errors.New("invalid y coordinate")
+	}
+	// 曲線上の点かどうかを確認する
+	if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// Len - 登録されている鍵の数
+func (v *AttestationVerifier) Len() int {
+	return len(v.keys)
+}
+
+// Required - アテステーションが必須かどうか
+func (v *AttestationVerifier) Required() bool {
+	return v.cfg.Required
+}
+
+// Verify - JWS（header.payload.signature）の署名と内容を検証する
+func (v *AttestationVerifier) Verify(token string, now time.Time) (*AttestationClaims, error) {
+	if token == "" {
+		return nil, errAttestationRequired
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", errAttestationInvalid)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: invalid header", errAttestationInvalid)
+	}
+	if header.Alg != "ES256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", errAttestationInvalid, header.Alg)
+	}
+	pub, ok := v.keys[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", errAttestationInvalid, header.Kid)
+	}
+
+	// ES256の署名は r || s（各32バイト）
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return nil, fmt.Errorf("%w: invalid signature", errAttestationInvalid)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(pub, digest[:], r, s) {
+		return nil, fmt.Errorf("%w: signature mismatch", errAttestationInvalid)
+	}
+
+	var claims AttestationClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: invalid payload", errAttestationInvalid)
+	}
+	if err := v.checkClaims(&claims, now); err != nil {
+		return nil, fmt.Errorf("%w: %v", errAttestationInvalid, err)
+	}
+	return &claims, nil
+}
+
+// checkClaims - 期限・署名者・Verified IDの種類と発行者を確認する
+func (v *AttestationVerifier) checkClaims(claims *AttestationClaims, now time.Time) error {
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	switch {
+	case claims.RequestID == "" || claims.WalletAddress == "":
+		return errors.New("requestId and wallet are required")
+	case claims.IssuedAt == 0 || claims.ExpiresAt == 0:
+		return errors.New("iat and exp are required")
+	case expiresAt.Sub(issuedAt) > attestationMaxLifetime:
+		return fmt.Errorf("lifetime exceeds %s", attestationMaxLifetime)
+	case issuedAt.After(now.Add(attestationLeeway)):
+		return errors.New("issued in the future")
+	case now.After(expiresAt.Add(attestationLeeway)):
+		return errors.New("expired")
+	case v.cfg.Issuer != "" && claims.Issuer != v.cfg.Issuer:
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case len(v.cfg.CredentialTypes) > 0 && !slices.Contains(v.cfg.CredentialTypes, claims.CredentialType):
+		return fmt.Errorf("credential type %q is not accepted", claims.CredentialType)
+	case len(v.cfg.CredentialIssuers) > 0 && !slices.Contains(v.cfg.CredentialIssuers, claims.CredentialIssuer):
+		return fmt.Errorf("credential issuer %q is not accepted", claims.CredentialIssuer)
+	}
+	return nil
+}
+
+// decodeSegment - base64urlのJSONを解析する
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// splitList - カンマ区切りの設定値を分割する（空要素は除く）
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
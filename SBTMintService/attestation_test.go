@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// attestationTestTime - テストのアテステーションの発行時刻
+var attestationTestTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// testJWKS - 公開鍵をJWK Setにする
+func testJWKS(t *testing.T, kid string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	data, err := json.Marshal(map[string][]jwk{"keys": {{
+		Kty: "EC",
+		Crv: "P-256",
+		Kid: kid,
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// signAttestation - C#バックエンドと同じくES256のJWSを作る
+func signAttestation(t *testing.T, key *ecdsa.PrivateKey, alg, kid string, claims AttestationClaims) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// TestAttestationVerify - 署名・形式・期限・発行者・Verified IDの種類の検証
+func TestAttestationVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := newAttestationVerifier(AttestationConfig{
+		JWKS:              testJWKS(t, "backend", key),
+		Issuer:            "nft-poc-backend",
+		CredentialTypes:   []string{"VerifiedEmployeeV2"},
+		CredentialIssuers: []string{"did:web:example.com"},
+		Required:          true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := AttestationClaims{
+		Issuer:           "nft-poc-backend",
+		RequestID:        "req-1",
+		WalletAddress:    "0x1111111111111111111111111111111111111111",
+		CredentialType:   "VerifiedEmployeeV2",
+		CredentialIssuer: "did:web:example.com",
+		IssuedAt:         attestationTestTime.Unix(),
+		ExpiresAt:        attestationTestTime.Add(5 * time.Minute).Unix(),
+	}
+	with := func(fn func(*AttestationClaims)) AttestationClaims {
+		c := valid
+		fn(&c)
+		return c
+	}
+	now := attestationTestTime.Add(time.Minute)
+	token := signAttestation(t, key, "ES256", "backend", valid)
+	unsigned := token[:strings.LastIndex(token, ".")]
+
+	tests := []struct {
+		name    string
+		token   string
+		at      time.Time
+		wantErr error
+	}{
+		{name: "valid", token: token},
+		{name: "within leeway after exp", token: token, at: attestationTestTime.Add(5*time.Minute + 30*time.Second)},
+		{name: "missing", token: "", wantErr: errAttestationRequired},
+		{name: "malformed", token: "a.b", wantErr: errAttestationInvalid},
+		{name: "invalid header", token: "!!.e30.AA", wantErr: errAttestationInvalid},
+		{name: "unsupported algorithm", token: signAttestation(t, key, "HS256", "backend", valid), wantErr: errAttestationInvalid},
+		{name: "unknown key", token: signAttestation(t, key, "ES256", "rotated", valid), wantErr: errAttestationInvalid},
+		{name: "signed by another key", token: signAttestation(t, other, "ES256", "backend", valid), wantErr: errAttestationInvalid},
+		{name: "short signature", token: unsigned + ".AAAA", wantErr: errAttestationInvalid},
+		{name: "missing wallet", token: signAttestation(t, key, "ES256", "backend", with(func(c *AttestationClaims) { c.WalletAddress = "" })), wantErr: errAttestationInvalid},
+		{name: "missing exp", token: signAttestation(t, key, "ES256", "backend", with(func(c *AttestationClaims) { c.ExpiresAt = 0 })), wantErr: errAttestationInvalid},
+		{name: "lifetime too long", token: signAttestation(t, key, "ES256", "backend", with(func(c *AttestationClaims) { c.ExpiresAt = attestationTestTime.Add(time.Hour).Unix() })), wantErr: errAttestationInvalid},
+		{name: "issued in the future", token: token, at: attestationTestTime.Add(-2 * time.Minute), wantErr: errAttestationInvalid},
+		{name: "expired", token: token, at: attestationTestTime.Add(7 * time.Minute), wantErr: errAttestationInvalid},
+		{name: "unexpected issuer", token: signAttestation(t, key, "ES256", "backend", with(func(c *AttestationClaims) { c.Issuer = "someone-else" })), wantErr: errAttestationInvalid},
+		{name: "credential type", token: signAttestation(t, key, "ES256", "backend", with(func(c *AttestationClaims) { c.CredentialType = "VerifiedStudent" })), wantErr: errAttestationInvalid},
+		{name: "credential issuer", token: signAttestation(t, key, "ES256", "backend", with(func(c *AttestationClaims) { c.CredentialIssuer = "did:web:evil.example" })), wantErr: errAttestationInvalid},
+	}
+	for _, tt := range tests {
+		at := now
+		if !tt.at.IsZero() {
+			at = tt.at
+		}
+		claims, err := verifier.Verify(tt.token, at)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: Verify error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Verify returned error: %v", tt.name, err)
+			continue
+		}
+		if claims.RequestID != valid.RequestID || claims.WalletAddress != valid.WalletAddress {
+			t.Errorf("%s: Verify claims = %+v, want %+v", tt.name, claims, valid)
+		}
+	}
+}
+
+// TestNewAttestationVerifier - 鍵の設定の検証
+func TestNewAttestationVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32)))
+	tests := []struct {
+		name     string
+		cfg      AttestationConfig
+		wantKeys int
+		wantErr  bool
+	}{
+		{name: "no keys", cfg: AttestationConfig{}},
+		{name: "no keys but required", cfg: AttestationConfig{Required: true}, wantErr: true},
+		{name: "one key", cfg: AttestationConfig{JWKS: testJWKS(t, "backend", key)}, wantKeys: 1},
+		{name: "invalid JSON", cfg: AttestationConfig{JWKS: "{"}, wantErr: true},
+		{name: "missing file", cfg: AttestationConfig{JWKS: "/nonexistent/jwks.json"}, wantErr: true},
+		{name: "unsupported curve", cfg: AttestationConfig{JWKS: fmt.Sprintf(`{"keys":[{"kty":"EC","crv":"P-384","kid":"a","x":%q,"y":%q}]}`, x, x)}, wantErr: true},
+		{name: "point not on curve", cfg: AttestationConfig{JWKS: fmt.Sprintf(`{"keys":[{"kty":"EC","crv":"P-256","kid":"a","x":%q,"y":%q}]}`, x, x)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		v, err := newAttestationVerifier(tt.cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: newAttestationVerifier succeeded, want error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: newAttestationVerifier returned error: %v", tt.name, err)
+			continue
+		}
+		if v.Len() != tt.wantKeys {
+			t.Errorf("%s: Len = %d, want %d", tt.name, v.Len(), tt.wantKeys)
+		}
+	}
+}
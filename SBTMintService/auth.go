@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIのスコープ
+const (
+	scopeMint      = "mint"       // POST /mint
+	scopeMintRead  = "mint:read"  // GET /mint/{id}, GET /mint/batch/{id}
+	scopeMintBatch = "mint:batch" // POST /mint/batch
+	scopeAdmin     = "admin"      // /signers（署名者の状態・排出）
+)
+
+// APIKey - 呼び出し元の認証に使うAPIキー
+type APIKey struct {
+	// ID - ログや制限に使う呼び出し元の識別子（秘密ではない）
+	ID     string   `json:"id"`
+	Secret string   `json:"secret"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt - 期限（ローテーションで旧キーを失効させる場合に指定）
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// allows - スコープを持っているか
+func (k *APIKey) allows(scope string) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, "*")
+}
+
+// APIKeyRing - 有効なAPIキーの集合
+//
+// MINT_API_KEYS のキーに加えて、MINT_API_KEYS_FILE のキーを変更のたびに読み直す。
+// 新旧のキーを同時に登録しておけば、呼び出し側を切り替えてから旧キーを削除するローテーションができる。
+type APIKeyRing struct {
+	path   string
+	static []APIKey
+
+	mu      sync.RWMutex
+	file    []APIKey
+	modTime time.Time
+}
+
+// apiKeyContextKey - 認証済みのAPIキーを保持するcontextのキー
+type apiKeyContextKey struct{}
+
+// newAPIKeyRing - 環境変数とファイルからAPIキーを読み込む
+func newAPIKeyRing(value, path string) (*APIKeyRing, error) {
+	static, err := parseAPIKeys(value)
+	if err != nil {
+		return nil, err
+	}
+	ring := &APIKeyRing{path: path, static: static}
+	if path != "" {
+		if err := ring.reload(); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+// parseAPIKeys - "id:secret:scope1|scope2" をカンマ区切りで並べた値を解析する
+func parseAPIKeys(value string) ([]APIKey, error) {
+	var keys []APIKey
+	for i, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			// 区切りが足りないと最初の要素がシークレットの場合があるため、内容は含めず位置だけ示す
+			return nil, fmt.Errorf("invalid API key entry at index %d: use id:secret:scope1|scope2", i)
+		}
+		keys = append(keys, APIKey{ID: parts[0], Secret: parts[1], Scopes: strings.Split(parts[2], "|")})
+	}
+	return keys, nil
+}
+
+// Len - 登録されているキーの数
+func (r *APIKeyRing) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.static) + len(r.file)
+}
+
+// authenticate - トークンに一致する有効なキーを返す
+func (r *APIKeyRing) authenticate(token string) *APIKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// 長さの違いで比較時間が変わらないようハッシュ同士を比較する
+	presented := sha256.Sum256([]byte(token))
+	now := time.Now()
+	var match *APIKey
+	for _, keys := range [][]APIKey{r.static, r.file} {
+		for i := range keys {
+			expected := sha256.Sum256([]byte(keys[i].Secret))
+			if subtle.ConstantTimeCompare(presented[:], expected[:]) != 1 {
+				continue
+			}
+			if keys[i].ExpiresAt != nil && now.After(*keys[i].ExpiresAt) {
+				continue
+			}
+			key := keys[i]
+			match = &key
+		}
+	}
+	return match
+}
+
+// watchFile - キーファイルの変更を定期的に確認して読み直す
+func (r *APIKeyRing) watchFile(interval time.Duration) {
+	if r.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := r.reload(); err != nil {
+			slog.Error("Failed to reload API keys", "error", err)
+		}
+	}
+}
+
+// reload - キーファイルが変更されていれば読み直す（JSONのAPIKey配列）
+func (r *APIKeyRing) reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read API key file: %w", err)
+	}
+	r.mu.RLock()
+	unchanged := info.ModTime().Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read API key file: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("invalid API key file %s: %w", r.path, err)
+	}
+	for i, key := range keys {
+		if key.ID == "" || key.Secret == "" || len(key.Scopes) == 0 {
+			return fmt.Errorf("invalid API key file %s: key at index %d needs id, secret and scopes", r.path, i)
+		}
+	}
+
+	r.mu.Lock()
+	r.file = keys
+	r.modTime = info.ModTime()
+	r.mu.Unlock()
+	slog.Info("Loaded API keys", "count", len(keys), "path", r.path)
+	return nil
+}
+
+// requireScope - Authorization: Bearer のAPIキーを検証し、scope を持つ呼び出し元だけ next に通す
+// authDisabled の場合（ローカル開発用）は検証しない
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// プリフライトは認証ヘッダーを送らないのでそのまま通す
+		if authDisabled || r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		var key *APIKey
+		if ok {
+			key = apiKeys.authenticate(strings.TrimSpace(token))
+		}
+		if key == nil {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sbt-mint"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(MintResponse{
+				Success:   false,
+				ErrorCode: errCodeUnauthenticated,
+				Message:   "Missing or invalid API key",
+			})
+			return
+		}
+		if !key.allows(scope) {
+			loggerFrom(r.Context()).Warn("API key is missing scope", "client", key.ID, "scope", scope)
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(MintResponse{
+				Success:   false,
+				ErrorCode: errCodeInsufficientScope,
+				Message:   fmt.Sprintf("API key is not allowed to use scope %s", scope),
+			})
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	}
+}
+
+// apiKeyFromContext - 認証済みのAPIキー（認証無効時はnil）
+func apiKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
+
+// clientID - ログ用の呼び出し元の識別子
+func clientID(r *http.Request) string {
+	if key := apiKeyFromContext(r.Context()); key != nil {
+		return key.ID
+	}
+	return "anonymous"
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestParseAPIKeys - MINT_API_KEYS の解析（エラーにシークレットを含めない）
+func TestParseAPIKeys(t *testing.T) {
+	tests := []struct {
+		value     string
+		want      []APIKey
+		wantIndex string // エラーに含まれる位置
+	}{
+		{value: "", want: nil},
+		{
+			value: "backend:s3cret:mint|mint:read",
+			want:  []APIKey{{ID: "backend", Secret: "s3cret", Scopes: []string{"mint", "mint:read"}}},
+		},
+		{
+			value: " a:one:mint , ,b:two:admin",
+			want: []APIKey{
+				{ID: "a", Secret: "one", Scopes: []string{"mint"}},
+				{ID: "b", Secret: "two", Scopes: []string{"admin"}},
+			},
+		},
+		{value: "s3cret", wantIndex: "index 0"},
+		{value: "a:one:mint,s3cret:mint", wantIndex: "index 1"},
+		{value: "a::mint", wantIndex: "index 0"},
+		{value: ":s3cret:mint", wantIndex: "index 0"},
+		{value: "a:s3cret:", wantIndex: "index 0"},
+	}
+	for _, tt := range tests {
+		got, err := parseAPIKeys(tt.value)
+		if tt.wantIndex != "" {
+			if err == nil {
+				t.Errorf("parseAPIKeys(%q) = %v, want error", tt.value, got)
+				continue
+			}
+			if !strings.Contains(err.Error(), tt.wantIndex) {
+				t.Errorf("parseAPIKeys(%q) error %q does not mention %s", tt.value, err, tt.wantIndex)
+			}
+			if strings.Contains(err.Error(), "s3cret") {
+				t.Errorf("parseAPIKeys(%q) error %q leaks the secret", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseAPIKeys(%q) returned error: %v", tt.value, err)
+			continue
+		}
+		if !slices.EqualFunc(got, tt.want, func(a, b APIKey) bool {
+			return a.ID == b.ID && a.Secret == b.Secret && slices.Equal(a.Scopes, b.Scopes)
+		}) {
+			t.Errorf("parseAPIKeys(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// alertTimeout - アラートのWebhookへの送信を待つ最大時間
+const alertTimeout = 10 * time.Second
+
+// 残高アラートの種類（BalanceAlert.Event）
+const (
+	alertBalanceLow        = "signer_balance_low"         // 警告のしきい値を下回った
+	alertBalanceBelowFloor = "signer_balance_below_floor" // MINT_SIGNER_MIN_BALANCE_POL を下回り、Mintを割り当てなくなった
+	alertBalanceRecovered  = "signer_balance_recovered"   // MINT_SIGNER_MIN_BALANCE_POL 以上に戻った
+)
+
+// BalanceConfig - 署名者の残高監視の設定
+type BalanceConfig struct {
+	// Interval - 残高を確認する間隔
+	Interval time.Duration
+	// WarnThresholds - 下回ったときにアラートを送る残高（wei）
+	WarnThresholds []*big.Int
+	// WebhookURL - アラートをPOSTするURL（空ならログのみ）
+	WebhookURL string
+	// MintGas - まだMintしていない場合に、1回のMint費用の見積もりに使うガス量
+	MintGas uint64
+}
+
+// BalanceAlert - Webhookに送る残高アラート
+// text はSlack・Discord等の受信Webhookでそのまま表示できるようにするための要約
+type BalanceAlert struct {
+	Event          string  `json:"event"`
+	Text           string  `json:"text"`
+	Signer         string  `json:"signer"`
+	Balance        string  `json:"balance"`             // wei
+	Threshold      string  `json:"threshold,omitempty"` // wei
+	RemainingMints *uint64 `json:"remainingMints,omitempty"`
+	ChainID        int64   `json:"chainId"`
+	Timestamp      string  `json:"timestamp"`
+}
+
+// BalanceMonitor - 署名者の残高とMint費用を定期的に取得し、しきい値を下回ったらアラートを送る
+type BalanceMonitor struct {
+	minter *Minter
+	cfg    BalanceConfig
+	client *http.Client
+}
+
+// newBalanceMonitor - 残高監視を作成する
+func newBalanceMonitor(minter *Minter, cfg BalanceConfig) *BalanceMonitor {
+	return &BalanceMonitor{
+		minter: minter,
+		cfg:    cfg,
+		client: &http.Client{Timeout: alertTimeout},
+	}
+}
+
+// watch - 起動直後と Interval ごとに残高を確認する
+func (b *BalanceMonitor) watch() {
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Interval)
+		if err := b.check(ctx); err != nil {
+			slog.Warn("Balance check failed", "error", err)
+		}
+		cancel()
+		<-ticker.C
+	}
+}
+
+// check - Mint費用の見積もりと署名者の残高を更新し、しきい値をまたいだ署名者についてアラートを送る
+func (b *BalanceMonitor) check(ctx context.Context) error {
+	m := b.minter
+	client, _, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 手数料が取得できない場合も残高は更新する（前回のMint費用の見積もりを使う）
+	if fees, err := m.suggestFees(ctx, client); err != nil {
+		slog.Warn("Could not estimate mint cost", "error", err)
+	} else {
+		m.pool.setMintCost(b.mintCost(fees))
+	}
+
+	changes, err := m.pool.refreshBalances(ctx, client)
+	if err := m.observe(err); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		for _, alert := range b.alerts(change) {
+			b.send(alert)
+		}
+	}
+	return nil
+}
+
+// mintCost - 1回のMintで送信に必要な残高（ガスリミット × 最大ガス単価）
+func (b *BalanceMonitor) mintCost(fees txFees) *big.Int {
+	gas := b.minter.lastMintGas.Load()
+	if gas == 0 {
+		gas = b.cfg.MintGas
+	}
+	price := fees.GasPrice
+	if fees.dynamic() {
+		price = fees.GasFeeCap
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(gas), price)
+}
+
+// alerts - 残高の変化に対するアラート
+// 警告のしきい値は一度に複数下回っても最も低いものだけを送る。起動直後の確認ではしきい値未満なら送る
+func (b *BalanceMonitor) alerts(change balanceChange) []BalanceAlert {
+	var alerts []BalanceAlert
+	crossed := func(threshold *big.Int) bool {
+		return change.Current.Cmp(threshold) < 0 && (change.Previous == nil || change.Previous.Cmp(threshold) >= 0)
+	}
+
+	var lowest *big.Int
+	for _, threshold := range b.cfg.WarnThresholds {
+		if crossed(threshold) && (lowest == nil || threshold.Cmp(lowest) < 0) {
+			lowest = threshold
+		}
+	}
+	if lowest != nil {
+		alerts = append(alerts, b.newAlert(alertBalanceLow, change, lowest,
+			"Signer %s balance %s is below the warning threshold %s"))
+	}
+
+	if floor := b.minter.pool.minBalance; floor != nil {
+		switch {
+		case crossed(floor):
+			alerts = append(alerts, b.newAlert(alertBalanceBelowFloor, change, floor,
+				"Signer %s balance %s is below the minimum %s, not assigning new mints"))
+		case change.Previous != nil && change.Previous.Cmp(floor) < 0 && change.Current.Cmp(floor) >= 0:
+			alerts = append(alerts, b.newAlert(alertBalanceRecovered, change, floor,
+				"Signer %s balance %s is back above the minimum %s"))
+		}
+	}
+	return alerts
+}
+
+// newAlert - アラートを作成する（format は署名者・残高・しきい値を受け取る）
+func (b *BalanceMonitor) newAlert(event string, change balanceChange, threshold *big.Int, format string) BalanceAlert {
+	var remaining *uint64
+	for _, status := range b.minter.pool.Status() {
+		if status.Address == change.Address.Hex() {
+			remaining = status.RemainingMints
+		}
+	}
+	return BalanceAlert{
+		Event:          event,
+		Text:           fmt.Sprintf(format, change.Address.Hex(), formatPOL(change.Current), formatPOL(threshold)),
+		Signer:         change.Address.Hex(),
+		Balance:        change.Current.String(),
+		Threshold:      threshold.String(),
+		RemainingMints: remaining,
+		ChainID:        b.minter.chainID.Int64(),
+		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// send - アラートをログに出し、Webhookが設定されていればPOSTする（失敗はログのみ）
+func (b *BalanceMonitor) send(alert BalanceAlert) {
+	level := slog.LevelWarn
+	if alert.Event == alertBalanceRecovered {
+		level = slog.LevelInfo
+	}
+	slog.Log(context.Background(), level, alert.Text,
+		"event", alert.Event, "signer", alert.Signer, "balance", alert.Balance, "threshold", alert.Threshold)
+	if b.cfg.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		slog.Error("Failed to encode balance alert", "error", err)
+		return
+	}
+	resp, err := b.client.Post(b.cfg.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Error("Failed to send balance alert", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Error("Balance alert webhook failed", "status", resp.Status)
+	}
+}
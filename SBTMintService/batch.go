@@ -0,0 +1,702 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// バケット名
+var (
+	batchesBucket   = []byte("batches")          // 一括MintのID → MintBatch
+	batchKeysBucket = []byte("batchIdempotency") // Idempotency-Key → 一括MintのID
+)
+
+// errBatchNotFound - 指定された一括Mintが存在しない
+var errBatchNotFound = errors.New("batch not found")
+
+// 一括Mintの状態（MintBatchResponse.Status）
+const (
+	batchStatusProcessing = "processing" // 終了していないジョブがある
+	batchStatusCompleted  = "completed"  // 全てのジョブが終了した（失敗を含む）
+)
+
+// batchItemRejected - ジョブを作らずに拒否した宛先の counts のキー
+const batchItemRejected = "rejected"
+
+// batchBalanceLookups - 一括Mintで同時に行う保有トークン数の確認の上限
+const batchBalanceLookups = 8
+
+// MintBatchRequest - 一括Mintのリクエスト
+type MintBatchRequest struct {
+	Recipients []BatchRecipient `json:"recipients"`
+}
+
+// BatchRecipient - 一括Mintの宛先
+type BatchRecipient struct {
+	WalletAddress string `json:"walletAddress"`
+	// IdempotencyKey - 再試行時の二重Mintを防ぐためのキー（/mint の Idempotency-Key と同じ扱い）
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// Attestation - C#バックエンドがVerified IDの提示を検証した証明（ES256のJWS）
+	Attestation string `json:"attestation,omitempty"`
+	// SIWE - 受取ウォレットの所有証明（EIP-4361のメッセージと署名）
+	SIWE *SIWEProof `json:"siwe,omitempty"`
+}
+
+// MintBatch - ディスクに永続化される一括Mint（宛先ごとのジョブID・拒否理由）
+type MintBatch struct {
+	ID             string          `json:"id"`
+	IdempotencyKey string          `json:"idempotencyKey,omitempty"` // 一括Mintの再試行を判別するキー（Idempotency-Keyヘッダー）
+	Items          []MintBatchItem `json:"items"`
+	CorrelationID  string          `json:"correlationId,omitempty"` // 一括Mintを作成したリクエストの X-Request-ID
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+// MintBatchItem - 一括Mintの宛先ごとの受付結果
+type MintBatchItem struct {
+	WalletAddress  string `json:"walletAddress"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	JobID          string `json:"jobId,omitempty"`
+	Replayed       bool   `json:"replayed,omitempty"` // 同じIdempotency-Keyの既存のジョブを返した
+	ErrorCode      string `json:"errorCode,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// MintBatchResponse - 一括MintのHTTPレスポンス
+type MintBatchResponse struct {
+	Success bool   `json:"success"`
+	BatchID string `json:"batchId,omitempty"`
+	Status  string `json:"status,omitempty"`
+	// Counts - ジョブの状態（拒否した宛先は rejected）ごとの宛先数
+	Counts    map[string]int    `json:"counts,omitempty"`
+	Items     []BatchItemResult `json:"items,omitempty"`
+	ErrorCode string            `json:"errorCode,omitempty"`
+	Message   string            `json:"message,omitempty"`
+}
+
+// BatchItemResult - 宛先ごとの結果（ジョブがあれば GET /mint/{id} と同じ内容）
+type BatchItemResult struct {
+	Index          int    `json:"index"`
+	WalletAddress  string `json:"walletAddress"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	Replayed       bool   `json:"replayed,omitempty"`
+	MintResponse
+}
+
+// response - 保存した一括Mintと各ジョブの現在の状態からレスポンスを作る
+func (b *MintBatch) response(jobs map[string]*MintJob) MintBatchResponse {
+	resp := MintBatchResponse{
+		Success: true,
+		BatchID: b.ID,
+		Status:  batchStatusCompleted,
+		Counts:  map[string]int{},
+		Items:   make([]BatchItemResult, len(b.Items)),
+	}
+	for i, item := range b.Items {
+		result := BatchItemResult{
+			Index:          i,
+			WalletAddress:  item.WalletAddress,
+			IdempotencyKey: item.IdempotencyKey,
+			Replayed:       item.Replayed,
+			MintResponse:   MintResponse{Success: false, ErrorCode: item.ErrorCode, Message: item.Message},
+		}
+		job := jobs[item.JobID]
+		switch {
+		case job != nil:
+			result.MintResponse = job.response()
+			resp.Counts[job.Status]++
+			if !job.finished() {
+				resp.Status = batchStatusProcessing
+			}
+		case item.JobID != "":
+			// ジョブを読み込めなかった
+			result.JobID = item.JobID
+			result.Message = "Mint job not found"
+			resp.Counts[jobStatusFailed]++
+		default:
+			resp.Counts[batchItemRejected]++
+		}
+		resp.Items[i] = result
+	}
+	return resp
+}
+
+// CreateBatch - 受け付けた宛先のジョブと一括Mintを1つのトランザクションで保存する
+// 宛先ごとの登録結果は batch.Items に設定する（1件も受け付けなかった場合は一括Mintを保存しない）
+// batch.IdempotencyKey の一括Mintが既にあれば何も書き込まずそのIDを返す
+func (s *JobStore) CreateBatch(batch *MintBatch, admissions []*batchAdmission) (string, error) {
+	var existing string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(batchKeysBucket)
+		if batch.IdempotencyKey != "" {
+			if id := keys.Get([]byte(batch.IdempotencyKey)); id != nil {
+				existing = string(id)
+				return nil
+			}
+		}
+
+		accepted := 0
+		for _, a := range admissions {
+			item := &batch.Items[a.index]
+			job, created, err := createJob(tx, a.job, a.maxPending)
+			switch {
+			case errors.Is(err, errWalletLimitReached):
+				item.reject(errCodeWalletLimitReached)
+				if job != nil {
+					item.Message = fmt.Sprintf("A mint for this wallet is already in progress (job %s)", job.ID)
+				}
+				continue
+			case errors.Is(err, errIdempotencyMismatch):
+				item.reject(errCodeIdempotencyMismatch)
+				continue
+			case err != nil:
+				return err
+			}
+			a.created = created
+			item.JobID = job.ID
+			item.Replayed = !created
+			accepted++
+		}
+		if accepted == 0 {
+			return nil
+		}
+
+		data, err := json.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(batchesBucket).Put([]byte(batch.ID), data); err != nil {
+			return err
+		}
+		if batch.IdempotencyKey != "" {
+			return keys.Put([]byte(batch.IdempotencyKey), []byte(batch.ID))
+		}
+		return nil
+	})
+	return existing, err
+}
+
+// GetBatch - 一括Mintと、その宛先のジョブ（ジョブID → ジョブ）を取得する
+func (s *JobStore) GetBatch(id string) (*MintBatch, map[string]*MintJob, error) {
+	var batch *MintBatch
+	var jobs map[string]*MintJob
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		batch, jobs, err = getBatch(tx, id)
+		return err
+	})
+	return batch, jobs, err
+}
+
+// LookupBatchKey - Idempotency-Keyに対応する一括Mintとその宛先のジョブを返す（無ければnil）
+func (s *JobStore) LookupBatchKey(key string) (*MintBatch, map[string]*MintJob, error) {
+	var batch *MintBatch
+	var jobs map[string]*MintJob
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(batchKeysBucket).Get([]byte(key))
+		if id == nil {
+			return nil
+		}
+		var err error
+		batch, jobs, err = getBatch(tx, string(id))
+		return err
+	})
+	return batch, jobs, err
+}
+
+// getBatch - トランザクション内で一括Mintと宛先のジョブを読み込む
+func getBatch(tx *bolt.Tx, id string) (*MintBatch, map[string]*MintJob, error) {
+	data := tx.Bucket(batchesBucket).Get([]byte(id))
+	if data == nil {
+		return nil, nil, errBatchNotFound
+	}
+	var batch MintBatch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode batch %s: %w", id, err)
+	}
+	jobs := map[string]*MintJob{}
+	for _, item := range batch.Items {
+		if item.JobID == "" {
+			continue
+		}
+		job, err := getJob(tx, item.JobID)
+		if errors.Is(err, errJobNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		jobs[job.ID] = job
+	}
+	return &batch, jobs, nil
+}
+
+// mintBatchHandler - 一括Mintエンドポイント
+// 宛先ごとに /mint と同じ検証を行ってジョブを登録し、宛先ごとの結果と状態を確認するための batchId を返す
+// Idempotency-Keyヘッダーが同じ再試行は新しい一括Mintを作らず、レート制限も消費せずに元の一括Mintを返す
+func mintBatchHandler(w http.ResponseWriter, r *http.Request) {
+	// CORSヘッダーを設定
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Request-ID, traceparent, tracestate")
+	w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-Request-ID, Idempotent-Replayed")
+
+	// OPTIONSリクエスト（プリフライト）の処理
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success: false,
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	// 読み取り専用モードではMintを受け付けない
+	if readOnlyReason != "" {
+		w.WriteHeader(errorCodeStatus(errCodeMintDisabled))
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success:   false,
+			ErrorCode: errCodeMintDisabled,
+			Message:   errorCodeMessage(errCodeMintDisabled),
+		})
+		return
+	}
+
+	// リクエストボディの解析
+	var req MintBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Recipients) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success:   false,
+			ErrorCode: errCodeInvalidRequest,
+			Message:   "Invalid request body: recipients must be a non-empty list",
+		})
+		return
+	}
+	if len(req.Recipients) > batchMaxRecipients {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success:   false,
+			ErrorCode: errCodeBatchTooLarge,
+			Message:   fmt.Sprintf("A batch can contain at most %d recipients", batchMaxRecipients),
+		})
+		return
+	}
+	batchKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(batchKey) > maxIdempotencyKeyLength {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success:   false,
+			ErrorCode: errCodeInvalidRequest,
+			Message:   "Idempotency key is too long",
+		})
+		return
+	}
+
+	logger := loggerFrom(r.Context()).With("client", clientID(r), "recipients", len(req.Recipients))
+
+	// 同じIdempotency-Keyの一括Mintがあれば元の一括Mintを返す（レート制限は消費しない）
+	if batchKey != "" {
+		batch, jobs, err := mintQueue.store.LookupBatchKey(batchKey)
+		if err != nil {
+			logger.Error("Error looking up idempotency key", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(MintBatchResponse{
+				Success: false,
+				Message: "Failed to look up idempotency key",
+			})
+			return
+		}
+		if batch != nil {
+			writeBatchReplay(w, logger, batch, jobs, req.Recipients)
+			return
+		}
+	}
+
+	// 一括Mint自体を1回のリクエストとして呼び出し元・送信元IPのレート制限を確認する（受取ウォレットの制限は宛先ごと）
+	allowed, limitedKey, retryAfter, err := rateLimiter.AllowRequest(r)
+	if err != nil {
+		logger.Error("Error checking rate limits", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success: false,
+			Message: "Failed to check rate limits",
+		})
+		return
+	}
+	if !allowed {
+		logger.Warn("Rate limited mint batch", "limit", limitedKey, "retryAfter", retryAfter.Round(time.Second).String())
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		w.WriteHeader(errorCodeStatus(errCodeRateLimited))
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success:   false,
+			ErrorCode: errCodeRateLimited,
+			Message:   errorCodeMessage(errCodeRateLimited),
+		})
+		return
+	}
+
+	batch := &MintBatch{
+		ID:             uuid.NewString(),
+		IdempotencyKey: batchKey,
+		Items:          make([]MintBatchItem, len(req.Recipients)),
+		CorrelationID:  requestIDFrom(r.Context()),
+		CreatedAt:      time.Now().UTC(),
+	}
+	logger = logger.With("batchId", batch.ID)
+	funded := minter.pool.Funded()
+
+	// 同じウォレットが複数回含まれる場合は最初の宛先だけMintする
+	seen := make(map[common.Address]int, len(req.Recipients))
+	var admissions []*batchAdmission
+	for i, recipient := range req.Recipients {
+		item := &batch.Items[i]
+		item.WalletAddress = strings.TrimSpace(recipient.WalletAddress)
+		item.IdempotencyKey = strings.TrimSpace(recipient.IdempotencyKey)
+
+		if !common.IsHexAddress(item.WalletAddress) {
+			item.reject(errCodeInvalidWallet)
+			continue
+		}
+		wallet := common.HexToAddress(item.WalletAddress)
+		if first, ok := seen[wallet]; ok {
+			item.reject(errCodeDuplicateRecipient)
+			item.Message = fmt.Sprintf("Wallet address already appears at index %d", first)
+			continue
+		}
+		seen[wallet] = i
+
+		if a := validateBatchRecipient(r, logger.With("wallet", item.WalletAddress), i, item, recipient, funded); a != nil {
+			admissions = append(admissions, a)
+		}
+	}
+
+	// 検証を通った宛先の保有トークン数をまとめて確認し、残った宛先だけ受取ウォレットのレート制限を消費する
+	if !mintPolicy.unlimited() {
+		admissions = checkBatchBalances(r.Context(), logger, batch, admissions)
+	}
+	admissions = chargeBatchWallets(logger, batch, admissions)
+
+	// 全宛先のジョブと一括Mintを1つのトランザクションで登録する
+	existing, err := mintQueue.EnqueueBatch(batch, admissions)
+	// ジョブを登録しなかった宛先の消費は戻す
+	refundBatchWallets(logger, admissions, err == nil && existing == "")
+	if err != nil {
+		logger.Error("Error saving mint batch", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success: false,
+			Message: "Failed to save mint batch",
+		})
+		return
+	}
+	if existing != "" {
+		// 同じIdempotency-Keyの一括Mintが並行して登録された
+		replay, jobs, err := mintQueue.store.GetBatch(existing)
+		if err != nil {
+			logger.Error("Error loading mint batch", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(MintBatchResponse{
+				Success: false,
+				Message: "Failed to load mint batch",
+			})
+			return
+		}
+		writeBatchReplay(w, logger, replay, jobs, req.Recipients)
+		return
+	}
+
+	// 1件も受け付けなかった場合は一括Mintを保存しない
+	accepted := 0
+	for _, item := range batch.Items {
+		if item.JobID != "" {
+			accepted++
+		}
+	}
+	if accepted == 0 {
+		logger.Warn("Rejected mint batch: no recipients accepted")
+		resp := batch.response(nil)
+		observeBatchItems(resp.Items)
+		resp.Success = false
+		resp.BatchID = ""
+		resp.Status = ""
+		resp.Message = "No recipients were accepted"
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	_, jobs, err := mintQueue.store.GetBatch(batch.ID)
+	if err != nil {
+		logger.Error("Error loading mint batch", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success: false,
+			Message: "Failed to load mint batch",
+		})
+		return
+	}
+	resp := batch.response(jobs)
+	observeBatchItems(resp.Items)
+	logger.Info("Mint batch queued", "accepted", accepted, "rejected", len(batch.Items)-accepted)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeBatchReplay - 同じIdempotency-Keyの一括Mintの現在の状態を返す（宛先が異なれば409）
+func writeBatchReplay(w http.ResponseWriter, logger *slog.Logger, batch *MintBatch, jobs map[string]*MintJob, recipients []BatchRecipient) {
+	if !batch.sameRecipients(recipients) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success:   false,
+			ErrorCode: errCodeIdempotencyMismatch,
+			Message:   "Idempotency key was already used for a different batch",
+		})
+		return
+	}
+	logger.Info("Replaying mint batch", "batchId", batch.ID)
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch.response(jobs))
+}
+
+// sameRecipients - 一括Mintが recipients と同じウォレットを同じ順に含むかどうか
+func (b *MintBatch) sameRecipients(recipients []BatchRecipient) bool {
+	if len(b.Items) != len(recipients) {
+		return false
+	}
+	for i, recipient := range recipients {
+		if !strings.EqualFold(b.Items[i].WalletAddress, strings.TrimSpace(recipient.WalletAddress)) {
+			return false
+		}
+	}
+	return true
+}
+
+// batchAdmission - 検証を通った宛先に登録するジョブ
+type batchAdmission struct {
+	index      int      // MintBatch.Items での位置
+	job        *MintJob // 登録するジョブ（同じIdempotency-Keyのジョブがあればそれを返す）
+	replay     bool     // 同じIdempotency-Keyのジョブが既にある
+	maxPending int      // 同じウォレットに許す未完了ジョブ数（負の値なら無制限）
+	charged    bool     // 受取ウォレットのレート制限を消費した
+	created    bool     // 新しいジョブを登録した（CreateBatch が設定する）
+}
+
+// validateBatchRecipient - 宛先に /mint と同じ検証（アテステーション・所有証明・残高）を行い、登録するジョブを返す
+// 拒否した場合は item にエラーコードを設定してnilを返す
+func validateBatchRecipient(r *http.Request, logger *slog.Logger, index int, item *MintBatchItem, recipient BatchRecipient, funded bool) *batchAdmission {
+	if len(item.IdempotencyKey) > maxIdempotencyKeyLength {
+		item.reject(errCodeInvalidRequest)
+		item.Message = "Idempotency key is too long"
+		return nil
+	}
+
+	// Verified IDの検証結果の確認（ウォレットとIdempotency-Keyがアテステーションと一致すること）
+	var credentialType string
+	if recipient.Attestation != "" || attestations.Required() {
+		claims, err := attestations.Verify(recipient.Attestation, time.Now())
+		if err != nil {
+			code := errCodeInvalidAttestation
+			if errors.Is(err, errAttestationRequired) {
+				code = errCodeAttestationRequired
+			}
+			item.reject(code)
+			return nil
+		}
+		if item.IdempotencyKey == "" {
+			item.IdempotencyKey = claims.RequestID
+		}
+		if !strings.EqualFold(claims.WalletAddress, item.WalletAddress) || claims.RequestID != item.IdempotencyKey {
+			item.reject(errCodeAttestationMismatch)
+			return nil
+		}
+		credentialType = claims.CredentialType
+	}
+
+	// 受取ウォレットの所有証明（SIWE）の確認
+	if recipient.SIWE != nil || siwe.Required() {
+		if _, err := siwe.Verify(recipient.SIWE, common.HexToAddress(item.WalletAddress), item.IdempotencyKey, time.Now()); err != nil {
+			switch {
+			case errors.Is(err, errSIWERequired):
+				item.reject(errCodeSIWERequired)
+			case errors.Is(err, errSIWEInvalid):
+				item.reject(errCodeInvalidSIWE)
+			default:
+				logger.Error("Error verifying wallet ownership proof", "error", err)
+				item.fail("Failed to verify wallet ownership proof")
+			}
+			return nil
+		}
+	}
+
+	// 署名者の残高を確認（同じキーの再試行は元の結果を返すので対象外）
+	replay, err := mintQueue.store.LookupIdempotencyKey(item.IdempotencyKey)
+	if err != nil {
+		logger.Error("Error looking up idempotency key", "error", err)
+		item.fail("Failed to look up idempotency key")
+		return nil
+	}
+	if replay == nil && !funded {
+		item.reject(errCodeInsufficientFunds)
+		return nil
+	}
+	return &batchAdmission{
+		index:      index,
+		job:        newMintJob(r.Context(), item.WalletAddress, item.IdempotencyKey, credentialType),
+		replay:     replay != nil,
+		maxPending: -1,
+	}
+}
+
+// chargeBatchWallets - 新しいジョブを登録する宛先の受取ウォレットのレート制限を消費する（超過した宛先は拒否して除く）
+func chargeBatchWallets(logger *slog.Logger, batch *MintBatch, admissions []*batchAdmission) []*batchAdmission {
+	return slices.DeleteFunc(admissions, func(a *batchAdmission) bool {
+		if a.replay {
+			return false
+		}
+		item := &batch.Items[a.index]
+		allowed, _, _, err := rateLimiter.AllowWallet(item.WalletAddress)
+		if err != nil {
+			logger.Error("Error checking rate limits", "wallet", item.WalletAddress, "error", err)
+			item.fail("Failed to check rate limits")
+			return true
+		}
+		if !allowed {
+			item.reject(errCodeRateLimited)
+			return true
+		}
+		a.charged = true
+		return false
+	})
+}
+
+// refundBatchWallets - chargeBatchWallets で消費したが新しいジョブを登録しなかった宛先のトークンを戻す
+// （未完了ジョブの上限・既存のジョブ・一括Mintを保存しなかった場合）。stored が false なら全て戻す
+func refundBatchWallets(logger *slog.Logger, admissions []*batchAdmission, stored bool) {
+	for _, a := range admissions {
+		if !a.charged || (stored && a.created) {
+			continue
+		}
+		if err := rateLimiter.RefundWallet(a.job.WalletAddress); err != nil {
+			logger.Error("Error refunding rate limit", "wallet", a.job.WalletAddress, "error", err)
+		}
+	}
+}
+
+// checkBatchBalances - 新しいジョブを登録する宛先の保有トークン数を並行して確認し、Mintポリシーの残り回数を設定する
+// 確認できなかった宛先は拒否して除く
+func checkBatchBalances(ctx context.Context, logger *slog.Logger, batch *MintBatch, admissions []*batchAdmission) []*batchAdmission {
+	errs := make([]error, len(admissions))
+	sem := make(chan struct{}, batchBalanceLookups)
+	var wg sync.WaitGroup
+	for i, a := range admissions {
+		if a.replay {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			held, err := minter.TokenBalance(ctx, a.job.WalletAddress)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			a.maxPending = mintPolicy.remaining(held)
+		}()
+	}
+	wg.Wait()
+
+	checked := admissions[:0]
+	for i, a := range admissions {
+		if errs[i] != nil {
+			logger.Error("Error checking token balance", "wallet", a.job.WalletAddress, "error", errs[i])
+			batch.Items[a.index].reject(errCodeRPCUnavailable)
+			continue
+		}
+		checked = append(checked, a)
+	}
+	return checked
+}
+
+// reject - 宛先をエラーコードで拒否する
+func (i *MintBatchItem) reject(code string) {
+	i.ErrorCode = code
+	i.Message = errorCodeMessage(code)
+}
+
+// fail - 宛先を内部エラーで拒否する
+func (i *MintBatchItem) fail(message string) {
+	i.ErrorCode = errCodeMintFailed
+	i.Message = message
+}
+
+// mintBatchStatusHandler - 一括Mintの宛先ごとの状態を返す
+func mintBatchStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// CORSヘッダーを設定
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// OPTIONSリクエスト（プリフライト）の処理
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success: false,
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	batch, jobs, err := mintQueue.store.GetBatch(strings.TrimSpace(r.PathValue("id")))
+	if errors.Is(err, errBatchNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success: false,
+			Message: "Mint batch not found",
+		})
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error loading mint batch", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MintBatchResponse{
+			Success: false,
+			Message: "Failed to load mint batch",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batch.response(jobs))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// エラーコード - APIレスポンスの errorCode
+// バックエンド・フロントエンドが分岐に使うため、一度公開した値は変更しないこと
+const (
+	errCodeInvalidRequest       = "INVALID_REQUEST"
+	errCodeUnauthenticated      = "UNAUTHENTICATED"
+	errCodeInsufficientScope    = "INSUFFICIENT_SCOPE"
+	errCodeAttestationRequired  = "ATTESTATION_REQUIRED"
+	errCodeInvalidAttestation   = "INVALID_ATTESTATION"
+	errCodeAttestationMismatch  = "ATTESTATION_MISMATCH"
+	errCodeSIWERequired         = "WALLET_PROOF_REQUIRED"
+	errCodeInvalidSIWE          = "INVALID_WALLET_PROOF"
+	errCodeInvalidWallet        = "INVALID_WALLET_ADDRESS"
+	errCodeBatchTooLarge        = "BATCH_TOO_LARGE"
+	errCodeDuplicateRecipient   = "DUPLICATE_RECIPIENT"
+	errCodeInvalidTokenID       = "INVALID_TOKEN_ID"
+	errCodeWalletLimitReached   = "WALLET_LIMIT_REACHED"
+	errCodeRateLimited          = "RATE_LIMITED"
+	errCodeIdempotencyMismatch  = "IDEMPOTENCY_KEY_MISMATCH"
+	errCodeUnauthorizedMinter   = "UNAUTHORIZED_MINTER"
+	errCodeInvalidOwner         = "INVALID_OWNER"
+	errCodeInvalidReceiver      = "INVALID_RECEIVER"
+	errCodeTokenAlreadyMinted   = "TOKEN_ALREADY_MINTED"
+	errCodeIncorrectOwner       = "INCORRECT_OWNER"
+	errCodeTokenNotFound        = "TOKEN_NOT_FOUND"
+	errCodeInsufficientApproval = "INSUFFICIENT_APPROVAL"
+	errCodeInvalidApprover      = "INVALID_APPROVER"
+	errCodeInvalidOperator      = "INVALID_OPERATOR"
+	errCodeInvalidTokenOwner    = "INVALID_TOKEN_OWNER"
+	errCodeExecutionReverted    = "EXECUTION_REVERTED"
+	errCodeTransactionReverted  = "TRANSACTION_REVERTED"
+	errCodeInsufficientFunds    = "INSUFFICIENT_FUNDS"
+	errCodeFeeCapExceeded       = "FEE_CAP_EXCEEDED"
+	errCodeGasLimitExceeded     = "GAS_LIMIT_EXCEEDED"
+	errCodeRPCUnavailable       = "RPC_UNAVAILABLE"
+	errCodeMintDisabled         = "MINT_DISABLED"
+	errCodeTransactionDropped   = "TRANSACTION_DROPPED"
+	errCodeMintFailed           = "MINT_FAILED"
+)
+
+// errorCodeInfo - エラーコードに対応するHTTPステータスと利用者向けメッセージ
+type errorCodeInfo struct {
+	Status  int
+	Message string
+}
+
+// errorCodes - エラーコード → HTTPステータス・メッセージ
+var errorCodes = map[string]errorCodeInfo{
+	errCodeInvalidRequest:       {http.StatusBadRequest, "Invalid request body"},
+	errCodeUnauthenticated:      {http.StatusUnauthorized, "Missing or invalid API key"},
+	errCodeInsufficientScope:    {http.StatusForbidden, "API key is not allowed to use this endpoint"},
+	errCodeAttestationRequired:  {http.StatusForbidden, "A verified ID attestation is required"},
+	errCodeInvalidAttestation:   {http.StatusForbidden, "Verified ID attestation is invalid or expired"},
+	errCodeAttestationMismatch:  {http.StatusForbidden, "Verified ID attestation does not match the wallet address or request ID"},
+	errCodeSIWERequired:         {http.StatusForbidden, "A Sign-In with Ethereum proof of wallet ownership is required"},
+	errCodeInvalidSIWE:          {http.StatusForbidden, "Sign-In with Ethereum proof is invalid, expired or already used"},
+	errCodeInvalidWallet:        {http.StatusBadRequest, "Invalid wallet address"},
+	errCodeBatchTooLarge:        {http.StatusRequestEntityTooLarge, "Batch contains too many recipients"},
+	errCodeDuplicateRecipient:   {http.StatusConflict, "Wallet address appears more than once in the batch"},
+	errCodeInvalidTokenID:       {http.StatusBadRequest, "Invalid token ID"},
+	errCodeWalletLimitReached:   {http.StatusConflict, "Wallet already holds the maximum number of tokens"},
+	errCodeRateLimited:          {http.StatusTooManyRequests, "Too many mint requests, retry later"},
+	errCodeIdempotencyMismatch:  {http.StatusConflict, "Idempotency key was already used for a different wallet address"},
+	errCodeUnauthorizedMinter:   {http.StatusServiceUnavailable, "Mint service account is not allowed to mint on the contract"},
+	errCodeInvalidOwner:         {http.StatusInternalServerError, "Contract owner is invalid"},
+	errCodeInvalidReceiver:      {http.StatusUnprocessableEntity, "Wallet address cannot receive the token"},
+	errCodeTokenAlreadyMinted:   {http.StatusConflict, "Token has already been minted"},
+	errCodeIncorrectOwner:       {http.StatusConflict, "Token is owned by a different address"},
+	errCodeTokenNotFound:        {http.StatusNotFound, "Token does not exist"},
+	errCodeInsufficientApproval: {http.StatusForbidden, "Operation is not approved for the token"},
+	errCodeInvalidApprover:      {http.StatusUnprocessableEntity, "Invalid approver"},
+	errCodeInvalidOperator:      {http.StatusUnprocessableEntity, "Invalid operator"},
+	errCodeInvalidTokenOwner:    {http.StatusUnprocessableEntity, "Invalid token owner address"},
+	errCodeExecutionReverted:    {http.StatusUnprocessableEntity, "Contract rejected the mint"},
+	errCodeTransactionReverted:  {http.StatusUnprocessableEntity, "SBT mint transaction reverted"},
+	errCodeInsufficientFunds:    {http.StatusServiceUnavailable, "Mint service account has insufficient funds"},
+	errCodeFeeCapExceeded:       {http.StatusServiceUnavailable, "Network fees exceed the configured maximum"},
+	errCodeGasLimitExceeded:     {http.StatusUnprocessableEntity, "Mint would exceed the configured gas limit"},
+	errCodeRPCUnavailable:       {http.StatusBadGateway, "Blockchain node is unavailable"},
+	errCodeMintDisabled:         {http.StatusServiceUnavailable, "Mint service is in read-only mode because its configuration failed validation"},
+	errCodeTransactionDropped:   {http.StatusInternalServerError, "SBT mint transaction was dropped"},
+	errCodeMintFailed:           {http.StatusInternalServerError, "SBT mint failed"},
+}
+
+// contractErrorCodes - IdentitySBTのカスタムエラー名 → エラーコード
+var contractErrorCodes = map[string]string{
+	"OwnableUnauthorizedAccount": errCodeUnauthorizedMinter,
+	"OwnableInvalidOwner":        errCodeInvalidOwner,
+	"ERC721InvalidReceiver":      errCodeInvalidReceiver,
+	"ERC721InvalidSender":        errCodeTokenAlreadyMinted, // _mint は既存トークンに対してこのエラーを返す
+	"ERC721IncorrectOwner":       errCodeIncorrectOwner,
+	"ERC721NonexistentToken":     errCodeTokenNotFound,
+	"ERC721InsufficientApproval": errCodeInsufficientApproval,
+	"ERC721InvalidApprover":      errCodeInvalidApprover,
+	"ERC721InvalidOperator":      errCodeInvalidOperator,
+	"ERC721InvalidOwner":         errCodeInvalidTokenOwner,
+}
+
+// classifyMintError - Mint処理のエラーをエラーコードとパラメータに変換する
+func classifyMintError(err error) (string, map[string]string) {
+	if revertErr := decodeRevert(err); revertErr != nil {
+		if code, ok := contractErrorCodes[revertErr.Name]; ok {
+			return code, revertErr.Params
+		}
+		if revertErr.Name == "Error" && revertErr.Reason != "" {
+			return errCodeExecutionReverted, map[string]string{"reason": revertErr.Reason}
+		}
+		return errCodeExecutionReverted, nil
+	}
+
+	switch {
+	case errors.Is(err, errMintReverted):
+		return errCodeTransactionReverted, nil
+	case errors.Is(err, errFeeCapExceeded):
+		return errCodeFeeCapExceeded, nil
+	case errors.Is(err, errGasLimitExceeded):
+		return errCodeGasLimitExceeded, nil
+	case strings.Contains(strings.ToLower(err.Error()), "insufficient funds"):
+		return errCodeInsufficientFunds, nil
+	case isConnectionError(err), errors.Is(err, context.DeadlineExceeded):
+		return errCodeRPCUnavailable, nil
+	default:
+		return errCodeMintFailed, nil
+	}
+}
+
+// errorCodeStatus - エラーコードに対応するHTTPステータス
+func errorCodeStatus(code string) int {
+	if info, ok := errorCodes[code]; ok {
+		return info.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// errorCodeMessage - エラーコードに対応する利用者向けメッセージ（未定義なら空）
+func errorCodeMessage(code string) string {
+	return errorCodes[code].Message
+}
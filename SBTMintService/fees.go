@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// FeeConfig - 手数料の上限設定（nilは上限なし）
+type FeeConfig struct {
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+}
+
+// errFeeCapExceeded - 現在のbase feeが設定の上限を超えている
+var errFeeCapExceeded = errors.New("base fee exceeds configured max fee")
+
+// txFees - トランザクションに設定する手数料
+// London以降のチェーンでは GasTipCap / GasFeeCap、それ以前は GasPrice を使う
+type txFees struct {
+	GasPrice  *big.Int
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+}
+
+// dynamic - EIP-1559トランザクションかどうか
+func (f txFees) dynamic() bool {
+	return f.GasFeeCap != nil
+}
+
+// apply - TransactOptsに手数料を設定する
+func (f txFees) apply(auth *bind.TransactOpts) {
+	auth.GasPrice = f.GasPrice
+	auth.GasTipCap = f.GasTipCap
+	auth.GasFeeCap = f.GasFeeCap
+}
+
+// String - ログ出力用
+func (f txFees) String() string {
+	if f.dynamic() {
+		return fmt.Sprintf("maxFee=%s gwei, maxPriorityFee=%s gwei", formatGwei(f.GasFeeCap), formatGwei(f.GasTipCap))
+	}
+	return fmt.Sprintf("gasPrice=%s gwei", formatGwei(f.GasPrice))
+}
+
+// suggestFees - 最新ブロックのbase feeとノードの推奨チップから手数料を決める
+// base feeの無いチェーン（London未対応）ではレガシーのガス価格を使う
+func (m *Minter) suggestFees(ctx context.Context, client chainClient) (fees txFees, err error) {
+	ctx, span := startRPC(ctx, rpcCallGasPrice)
+	defer func() { span.end(err) }()
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err := m.observe(err); err != nil {
+		return txFees{}, fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	if head.BaseFee == nil {
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err := m.observe(err); err != nil {
+			return txFees{}, fmt.Errorf("failed to suggest gas price: %w", err)
+		}
+		if max := m.fees.MaxFeePerGas; max != nil && gasPrice.Cmp(max) > 0 {
+			gasPrice = new(big.Int).Set(max)
+		}
+		return txFees{GasPrice: gasPrice}, nil
+	}
+
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err := m.observe(err); err != nil {
+		return txFees{}, fmt.Errorf("failed to suggest gas tip cap: %w", err)
+	}
+	if max := m.fees.MaxPriorityFeePerGas; max != nil && tip.Cmp(max) > 0 {
+		tip = new(big.Int).Set(max)
+	}
+
+	// base feeが2ブロック連続で最大まで上がっても取り込まれるよう2倍にしておく
+	feeCap := new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tip)
+	if max := m.fees.MaxFeePerGas; max != nil && feeCap.Cmp(max) > 0 {
+		if max.Cmp(head.BaseFee) < 0 {
+			return txFees{}, fmt.Errorf("%w: base fee %s gwei, max fee %s gwei",
+				errFeeCapExceeded, formatGwei(head.BaseFee), formatGwei(max))
+		}
+		feeCap = new(big.Int).Set(max)
+	}
+	if tip.Cmp(feeCap) > 0 {
+		tip = new(big.Int).Set(feeCap)
+	}
+	return txFees{GasTipCap: tip, GasFeeCap: feeCap}, nil
+}
+
+// parseGwei - gwei単位の10進数文字列をweiに変換する（例: "30", "1.5"）
+func parseGwei(value string) (*big.Int, error) {
+	f, ok := new(big.Float).SetPrec(256).SetString(value)
+	if !ok || f.Sign() < 0 {
+		return nil, fmt.Errorf("invalid gwei amount %q", value)
+	}
+	wei, _ := f.Mul(f, big.NewFloat(params.GWei)).Int(nil)
+	return wei, nil
+}
+
+// formatGwei - weiをgwei単位の文字列にする
+func formatGwei(wei *big.Int) string {
+	if wei == nil {
+		return "0"
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.GWei)).Text('f', -1)
+}
+
+// formatFeeCap - 上限設定をログ用の文字列にする
+func formatFeeCap(wei *big.Int) string {
+	if wei == nil {
+		return "unlimited"
+	}
+	return formatGwei(wei) + " gwei"
+}
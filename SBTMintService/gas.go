@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GasConfig - ガスリミットの見積もり設定
+type GasConfig struct {
+	// Multiplier - 見積もり値に掛ける安全係数
+	Multiplier float64
+	// MaxGas - ガスリミットの上限
+	MaxGas uint64
+}
+
+// errGasLimitExceeded - 見積もったガスが設定の上限を超えている
+var errGasLimitExceeded = errors.New("estimated gas exceeds the configured limit")
+
+// estimateMintGas - safeMint のガスを見積もり、安全係数を掛けたガスリミットを返す
+// 見積もり時にrevertした場合は解析済みの *RevertError を返す（トランザクションは送信しない）
+func (m *Minter) estimateMintGas(ctx context.Context, client chainClient, from, recipient common.Address, fees txFees) (gasLimit uint64, err error) {
+	data, err := identitySBTABI.Pack("safeMint", recipient)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode safeMint call: %w", err)
+	}
+
+	ctx, span := startRPC(ctx, rpcCallEstimateGas)
+	defer func() { span.end(err) }()
+	estimated, err := client.EstimateGas(ctx, ethereum.CallMsg{
+		From:      from,
+		To:        &m.contract,
+		Data:      data,
+		GasPrice:  fees.GasPrice,
+		GasTipCap: fees.GasTipCap,
+		GasFeeCap: fees.GasFeeCap,
+	})
+	if revertErr := decodeRevert(err); revertErr != nil {
+		m.observe(nil)
+		return 0, revertErr
+	}
+	if err := m.observe(err); err != nil {
+		return 0, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+
+	if m.gas.MaxGas > 0 && estimated > m.gas.MaxGas {
+		return 0, fmt.Errorf("%w: estimated %d, limit %d", errGasLimitExceeded, estimated, m.gas.MaxGas)
+	}
+	limit := uint64(float64(estimated) * m.gas.Multiplier)
+	if limit < estimated {
+		limit = estimated
+	}
+	if m.gas.MaxGas > 0 && limit > m.gas.MaxGas {
+		limit = m.gas.MaxGas
+	}
+	return limit, nil
+}
@@ -0,0 +1,613 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	bolt "go.etcd.io/bbolt"
+)
+
+// reorgDepth - reorg検出のためにブロックハッシュと取り消し情報を残すブロック数
+const reorgDepth = 256
+
+// インデックスのバケット名
+var (
+	tokensBucket       = []byte("tokens")        // トークンID(32バイト) → TokenRecord
+	ownersBucket       = []byte("owners")        // 所有者(20バイト)+トークンID(32バイト) → 空
+	indexedBlockBucket = []byte("indexedBlocks") // ブロック番号 → ブロックハッシュ
+	indexUndoBucket    = []byte("indexUndo")     // ブロック番号 → そのブロックで変更する前のトークン情報
+	indexMetaBucket    = []byte("indexMeta")
+)
+
+// indexMetaBucket のキー
+var (
+	nextBlockKey  = []byte("nextBlock")  // 次にインデックスするブロック番号
+	startBlockKey = []byte("startBlock") // 検出したコントラクトのデプロイブロック（DetectStartBlock 時）
+)
+
+var (
+	// errTokenNotFound - インデックスにトークンが存在しない
+	errTokenNotFound = errors.New("token not found")
+	// errHistoricalStateUnavailable - RPCノードが過去のブロックの状態を返さない（アーカイブノードではない）
+	errHistoricalStateUnavailable = errors.New("RPC node does not serve historical state")
+)
+
+// TokenRecord - Transferイベントから作成したトークンの情報
+type TokenRecord struct {
+	TokenID           string    `json:"tokenId"`
+	Owner             string    `json:"owner"`
+	MintBlock         uint64    `json:"mintBlock"`
+	MintTime          time.Time `json:"mintTime"`
+	MintTxHash        string    `json:"mintTxHash"`
+	LastTransferBlock uint64    `json:"lastTransferBlock"`
+	LastTxHash        string    `json:"lastTxHash"`
+}
+
+// tokenUndo - reorg時に1つのTransferを取り消すための情報
+type tokenUndo struct {
+	TokenID  string       `json:"tokenId"`
+	Previous *TokenRecord `json:"previous,omitempty"` // nilなら変更前は存在しなかった
+}
+
+// IndexerConfig - Transferイベントのインデックス設定
+type IndexerConfig struct {
+	// StartBlock - バックフィルを開始するブロック（コントラクトのデプロイブロックを指定する）
+	StartBlock uint64
+	// DetectStartBlock - StartBlock の代わりにコントラクトのデプロイブロックをチェーンから探して開始する
+	// （MINT_INDEXER_START_BLOCK=auto、過去の状態を返せるアーカイブノードが必要）
+	DetectStartBlock bool
+	// Confirmations - 最新ブロックからこのブロック数だけ遅れてインデックスする
+	Confirmations uint64
+	// BatchSize - 1回の eth_getLogs で取得するブロック数
+	BatchSize uint64
+	// Interval - 新しいブロックを確認する間隔
+	Interval time.Duration
+}
+
+// IndexerStatus - インデックスの状態
+type IndexerStatus struct {
+	LastIndexedBlock uint64     `json:"lastIndexedBlock"`
+	HeadBlock        uint64     `json:"headBlock"`
+	Synced           bool       `json:"synced"`
+	LastSyncedAt     *time.Time `json:"lastSyncedAt,omitempty"`
+	LastError        string     `json:"lastError,omitempty"`
+}
+
+// TokenIndex - IdentitySBTのTransferイベントから作るローカルの保有者レジストリ
+//
+// StartBlock からバックフィルした後は新しいブロックを追跡する。
+// インデックス済みブロックのハッシュを保存しておき、チェーンと食い違った場合はreorgとして巻き戻す。
+type TokenIndex struct {
+	db     *bolt.DB
+	minter *Minter
+	cfg    IndexerConfig
+
+	mu     sync.Mutex
+	status IndexerStatus
+}
+
+// newTokenIndex - ジョブストアと同じデータベースにインデックスを作成する
+func newTokenIndex(db *bolt.DB, minter *Minter, cfg IndexerConfig) (*TokenIndex, error) {
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 2000
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{tokensBucket, ownersBucket, indexedBlockBucket, indexUndoBucket, indexMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize token index: %w", err)
+	}
+	return &TokenIndex{db: db, minter: minter, cfg: cfg}, nil
+}
+
+// watchTransfers - 定期的に新しいブロックをインデックスする（起動直後にバックフィルを始める）
+func (ti *TokenIndex) watchTransfers() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		err := ti.sync(ctx)
+		cancel()
+		ti.mu.Lock()
+		if err != nil {
+			ti.status.LastError = err.Error()
+		} else {
+			now := time.Now().UTC()
+			ti.status.LastSyncedAt = &now
+			ti.status.LastError = ""
+		}
+		ti.mu.Unlock()
+		if err != nil {
+			slog.Warn("Token indexer failed", "error", err)
+		}
+		time.Sleep(ti.cfg.Interval)
+	}
+}
+
+// Status - インデックスの状態を返す
+func (ti *TokenIndex) Status() IndexerStatus {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	return ti.status
+}
+
+// Token - トークンIDの情報を返す（未発行・焼却済みは errTokenNotFound）
+func (ti *TokenIndex) Token(tokenID *big.Int) (*TokenRecord, error) {
+	var record *TokenRecord
+	err := ti.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getToken(tx, tokenKey(tokenID))
+		if err == nil && record == nil {
+			return errTokenNotFound
+		}
+		return err
+	})
+	return record, err
+}
+
+// TokensOf - ウォレットが保有するトークンをトークンID順に返す
+func (ti *TokenIndex) TokensOf(owner common.Address) ([]*TokenRecord, error) {
+	var records []*TokenRecord
+	err := ti.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(ownersBucket).Cursor()
+		prefix := owner.Bytes()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			record, err := getToken(tx, k[len(prefix):])
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				records = append(records, record)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// sync - 確定したブロックまでインデックスを進める
+func (ti *TokenIndex) sync(ctx context.Context) error {
+	client, instance, err := ti.minter.connect(ctx)
+	if err != nil {
+		return err
+	}
+	head, err := client.BlockNumber(ctx)
+	if err := ti.minter.observe(err); err != nil {
+		return fmt.Errorf("failed to get block number: %w", err)
+	}
+	ti.mu.Lock()
+	ti.status.HeadBlock = head
+	ti.mu.Unlock()
+	if head < ti.cfg.Confirmations {
+		return nil
+	}
+	target := head - ti.cfg.Confirmations
+
+	start, err := ti.startBlock(ctx, client, head)
+	if err != nil {
+		return err
+	}
+	if err := ti.checkReorg(ctx, client, start); err != nil {
+		return err
+	}
+
+	next, err := ti.nextBlock(start)
+	if err != nil {
+		return err
+	}
+	if next > start {
+		ti.mu.Lock()
+		ti.status.LastIndexedBlock = next - 1
+		ti.mu.Unlock()
+	}
+	for next <= target {
+		end := next + ti.cfg.BatchSize - 1
+		if end > target {
+			end = target
+		}
+		if err := ti.indexRange(ctx, client, instance, next, end); err != nil {
+			return err
+		}
+		next = end + 1
+	}
+
+	ti.mu.Lock()
+	ti.status.Synced = true
+	ti.mu.Unlock()
+	return nil
+}
+
+// indexRange - from〜to のTransferイベントを取得して反映する
+func (ti *TokenIndex) indexRange(ctx context.Context, client chainClient, instance *IdentitySBT, from, to uint64) error {
+	iter, err := instance.FilterTransfer(&bind.FilterOpts{Start: from, End: &to, Context: ctx}, nil, nil, nil)
+	if err := ti.minter.observe(err); err != nil {
+		return fmt.Errorf("failed to filter Transfer events in blocks %d-%d: %w", from, to, err)
+	}
+	var events []*IdentitySBTTransfer
+	for iter.Next() {
+		events = append(events, iter.Event)
+	}
+	err = iter.Error()
+	iter.Close()
+	if err := ti.minter.observe(err); err != nil {
+		return fmt.Errorf("failed to read Transfer events in blocks %d-%d: %w", from, to, err)
+	}
+
+	// Mint時刻のためのブロックタイムスタンプと、reorg検出用の範囲末尾のハッシュ
+	blockTimes := make(map[uint64]time.Time)
+	for _, event := range events {
+		n := event.Raw.BlockNumber
+		if _, ok := blockTimes[n]; ok || event.From != (common.Address{}) {
+			continue
+		}
+		header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+		if err := ti.minter.observe(err); err != nil {
+			return fmt.Errorf("failed to get block %d: %w", n, err)
+		}
+		blockTimes[n] = time.Unix(int64(header.Time), 0).UTC()
+	}
+	last, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(to))
+	if err := ti.minter.observe(err); err != nil {
+		return fmt.Errorf("failed to get block %d: %w", to, err)
+	}
+
+	err = ti.db.Update(func(tx *bolt.Tx) error {
+		undo := make(map[uint64][]tokenUndo)
+		hashes := map[uint64]common.Hash{to: last.Hash()}
+		for _, event := range events {
+			n := event.Raw.BlockNumber
+			entry, err := applyTransfer(tx, event, blockTimes[n])
+			if err != nil {
+				return err
+			}
+			undo[n] = append(undo[n], entry)
+			hashes[n] = event.Raw.BlockHash
+		}
+
+		for n, entries := range undo {
+			data, err := json.Marshal(entries)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(indexUndoBucket).Put(blockKey(n), data); err != nil {
+				return err
+			}
+		}
+		for n, hash := range hashes {
+			if err := tx.Bucket(indexedBlockBucket).Put(blockKey(n), hash.Bytes()); err != nil {
+				return err
+			}
+		}
+		if to > reorgDepth {
+			if err := pruneBefore(tx, to-reorgDepth); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(indexMetaBucket).Put(nextBlockKey, blockKey(to+1))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store Transfer events in blocks %d-%d: %w", from, to, err)
+	}
+
+	ti.mu.Lock()
+	ti.status.LastIndexedBlock = to
+	ti.mu.Unlock()
+	if len(events) > 0 {
+		slog.Info("Indexed Transfer events", "count", len(events), "fromBlock", from, "toBlock", to)
+	}
+	return nil
+}
+
+// checkReorg - 最後にインデックスしたブロックのハッシュがチェーンと一致するか確認し、
+// 食い違っていれば一致するブロックまで巻き戻す
+func (ti *TokenIndex) checkReorg(ctx context.Context, client chainClient, start uint64) error {
+	var stored []uint64
+	hashes := make(map[uint64]common.Hash)
+	err := ti.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexedBlockBucket).ForEach(func(k, v []byte) error {
+			n := binary.BigEndian.Uint64(k)
+			stored = append(stored, n)
+			hashes[n] = common.BytesToHash(v)
+			return nil
+		})
+	})
+	if err != nil || len(stored) == 0 {
+		return err
+	}
+
+	// 新しいブロックから順に、チェーンと一致する最初のブロックを探す
+	sort.Slice(stored, func(i, k int) bool { return stored[i] > stored[k] })
+	for i, n := range stored {
+		header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+		if err := ti.minter.observe(err); err != nil {
+			return fmt.Errorf("failed to get block %d: %w", n, err)
+		}
+		if header.Hash() != hashes[n] {
+			continue
+		}
+		if i == 0 {
+			return nil
+		}
+		slog.Warn("Chain reorganization detected, rolling back token index", "block", n)
+		return ti.rollback(n)
+	}
+
+	slog.Warn("Chain reorganization too deep, rebuilding token index", "maxDepth", reorgDepth, "fromBlock", start)
+	return ti.reset()
+}
+
+// rollback - block より後のTransferを取り消し、block+1 から再インデックスさせる
+func (ti *TokenIndex) rollback(block uint64) error {
+	err := ti.db.Update(func(tx *bolt.Tx) error {
+		var blocks [][]byte
+		c := tx.Bucket(indexUndoBucket).Cursor()
+		for k, _ := c.Seek(blockKey(block + 1)); k != nil; k, _ = c.Next() {
+			blocks = append(blocks, append([]byte(nil), k...))
+		}
+		// 新しいブロックから、ブロック内は後のTransferから取り消す
+		for i := len(blocks) - 1; i >= 0; i-- {
+			var entries []tokenUndo
+			if err := json.Unmarshal(tx.Bucket(indexUndoBucket).Get(blocks[i]), &entries); err != nil {
+				return err
+			}
+			for k := len(entries) - 1; k >= 0; k-- {
+				tokenID, ok := new(big.Int).SetString(entries[k].TokenID, 10)
+				if !ok {
+					return fmt.Errorf("invalid token ID %q in undo log", entries[k].TokenID)
+				}
+				if err := putToken(tx, tokenKey(tokenID), entries[k].Previous); err != nil {
+					return err
+				}
+			}
+			if err := tx.Bucket(indexUndoBucket).Delete(blocks[i]); err != nil {
+				return err
+			}
+		}
+
+		var hashes [][]byte
+		hc := tx.Bucket(indexedBlockBucket).Cursor()
+		for k, _ := hc.Seek(blockKey(block + 1)); k != nil; k, _ = hc.Next() {
+			hashes = append(hashes, append([]byte(nil), k...))
+		}
+		for _, k := range hashes {
+			if err := tx.Bucket(indexedBlockBucket).Delete(k); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(indexMetaBucket).Put(nextBlockKey, blockKey(block+1))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to roll back token index: %w", err)
+	}
+	ti.mu.Lock()
+	ti.status.LastIndexedBlock = block
+	ti.mu.Unlock()
+	return nil
+}
+
+// reset - インデックスを消去して開始ブロックから作り直させる（検出したデプロイブロックは残す）
+func (ti *TokenIndex) reset() error {
+	err := ti.db.Update(func(tx *bolt.Tx) error {
+		start := slices.Clone(tx.Bucket(indexMetaBucket).Get(startBlockKey))
+		for _, name := range [][]byte{tokensBucket, ownersBucket, indexedBlockBucket, indexUndoBucket, indexMetaBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		if start != nil {
+			return tx.Bucket(indexMetaBucket).Put(startBlockKey, start)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset token index: %w", err)
+	}
+	ti.mu.Lock()
+	ti.status.LastIndexedBlock = 0
+	ti.status.Synced = false
+	ti.mu.Unlock()
+	return nil
+}
+
+// startBlock - バックフィルを開始するブロック
+// DetectStartBlock なら、コントラクトのデプロイブロックを探して保存する（次回からは保存した値を使う）
+func (ti *TokenIndex) startBlock(ctx context.Context, client chainClient, head uint64) (uint64, error) {
+	if !ti.cfg.DetectStartBlock {
+		return ti.cfg.StartBlock, nil
+	}
+	var start uint64
+	var found bool
+	err := ti.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(indexMetaBucket).Get(startBlockKey); v != nil {
+			start, found = binary.BigEndian.Uint64(v), true
+		}
+		return nil
+	})
+	if err != nil || found {
+		return start, err
+	}
+
+	start, err = ti.deployBlock(ctx, client, head)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find contract deploy block (set MINT_INDEXER_START_BLOCK to the deploy block): %w", err)
+	}
+	err = ti.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexMetaBucket).Put(startBlockKey, blockKey(start))
+	})
+	if err != nil {
+		return 0, err
+	}
+	slog.Info("Detected contract deploy block for token index", "block", start)
+	return start, nil
+}
+
+// deployBlock - コントラクトのコードが存在する最初のブロックを二分探索で探す（過去の状態を返せるノードが必要）
+func (ti *TokenIndex) deployBlock(ctx context.Context, client chainClient, head uint64) (uint64, error) {
+	hasCode := func(n uint64) (bool, error) {
+		code, err := client.CodeAt(ctx, ti.minter.contract, new(big.Int).SetUint64(n))
+		if err := ti.minter.observe(err); err != nil {
+			// ノードが応答したエラーは、その時点の状態を保持していないことを意味する
+			var rpcErr rpc.Error
+			if n < head && errors.As(err, &rpcErr) {
+				return false, fmt.Errorf("%w: failed to get code at block %d: %v", errHistoricalStateUnavailable, n, err)
+			}
+			return false, fmt.Errorf("failed to get code at block %d: %w", n, err)
+		}
+		return len(code) > 0, nil
+	}
+	ok, err := hasCode(head)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("no contract code at %s", ti.minter.contract.Hex())
+	}
+	low, high := uint64(0), head
+	for low < high {
+		mid := low + (high-low)/2
+		ok, err := hasCode(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low, nil
+}
+
+// nextBlock - 次にインデックスするブロック番号（未インデックスなら start）
+func (ti *TokenIndex) nextBlock(start uint64) (uint64, error) {
+	next := start
+	err := ti.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(indexMetaBucket).Get(nextBlockKey); v != nil {
+			next = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return next, err
+}
+
+// applyTransfer - Transferイベントを1件反映し、取り消し用の情報を返す
+func applyTransfer(tx *bolt.Tx, event *IdentitySBTTransfer, mintTime time.Time) (tokenUndo, error) {
+	key := tokenKey(event.TokenId)
+	previous, err := getToken(tx, key)
+	if err != nil {
+		return tokenUndo{}, err
+	}
+	undo := tokenUndo{TokenID: event.TokenId.String(), Previous: previous}
+
+	// to = 0x0 は焼却
+	if event.To == (common.Address{}) {
+		return undo, putToken(tx, key, nil)
+	}
+
+	record := &TokenRecord{TokenID: event.TokenId.String()}
+	if previous != nil {
+		*record = *previous
+	}
+	if event.From == (common.Address{}) {
+		record.MintBlock = event.Raw.BlockNumber
+		record.MintTime = mintTime
+		record.MintTxHash = event.Raw.TxHash.Hex()
+	}
+	record.Owner = event.To.Hex()
+	record.LastTransferBlock = event.Raw.BlockNumber
+	record.LastTxHash = event.Raw.TxHash.Hex()
+	return undo, putToken(tx, key, record)
+}
+
+// getToken - トランザクション内でトークンを読み込む（存在しなければnil）
+func getToken(tx *bolt.Tx, key []byte) (*TokenRecord, error) {
+	data := tx.Bucket(tokensBucket).Get(key)
+	if data == nil {
+		return nil, nil
+	}
+	var record TokenRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode token %x: %w", key, err)
+	}
+	return &record, nil
+}
+
+// putToken - トランザクション内でトークンを書き込み、所有者の索引を更新する（recordがnilなら削除）
+func putToken(tx *bolt.Tx, key []byte, record *TokenRecord) error {
+	previous, err := getToken(tx, key)
+	if err != nil {
+		return err
+	}
+	owners := tx.Bucket(ownersBucket)
+	if previous != nil {
+		if err := owners.Delete(ownerKey(previous.Owner, key)); err != nil {
+			return err
+		}
+	}
+	if record == nil {
+		return tx.Bucket(tokensBucket).Delete(key)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := owners.Put(ownerKey(record.Owner, key), nil); err != nil {
+		return err
+	}
+	return tx.Bucket(tokensBucket).Put(key, data)
+}
+
+// pruneBefore - block より前のハッシュと取り消し情報を削除する
+func pruneBefore(tx *bolt.Tx, block uint64) error {
+	for _, name := range [][]byte{indexedBlockBucket, indexUndoBucket} {
+		b := tx.Bucket(name)
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) < block; k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tokenKey - トークンIDのキー（32バイトのビッグエンディアンでID順に並ぶ）
+func tokenKey(tokenID *big.Int) []byte {
+	return common.BigToHash(tokenID).Bytes()
+}
+
+// ownerKey - 所有者の索引のキー
+func ownerKey(owner string, tokenKey []byte) []byte {
+	return append(common.HexToAddress(owner).Bytes(), tokenKey...)
+}
+
+// blockKey - ブロック番号のキー
+func blockKey(n uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, n)
+	return key
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// testRPCError - ノードが返すJSON-RPCエラー
+type testRPCError struct{ message string }
+
+func (e testRPCError) Error() string  { return e.message }
+func (e testRPCError) ErrorCode() int { return -32000 }
+
+// prunedStateClient - 最新ブロック以外の状態を返さない（アーカイブノードではない）クライアント
+type prunedStateClient struct {
+	chainClient
+	head uint64
+}
+
+func (c prunedStateClient) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber != nil && blockNumber.Uint64() < c.head {
+		return nil, testRPCError{message: "missing trie node"}
+	}
+	return c.chainClient.CodeAt(ctx, contract, blockNumber)
+}
+
+// TestDeployBlock - デプロイブロックの二分探索と、過去の状態を返せないノードでのエラー
+func TestDeployBlock(t *testing.T) {
+	m, sim := newSimulatedMinter(t)
+	for range 5 {
+		sim.Commit()
+	}
+	ctx := context.Background()
+	client, _, err := m.connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti := &TokenIndex{minter: m}
+
+	// デプロイは最初のブロックに含まれる
+	if block, err := ti.deployBlock(ctx, client, head); err != nil || block != 1 {
+		t.Errorf("deployBlock = %d, %v, want 1", block, err)
+	}
+
+	_, err = ti.deployBlock(ctx, prunedStateClient{chainClient: client, head: head}, head)
+	if !errors.Is(err, errHistoricalStateUnavailable) {
+		t.Errorf("deployBlock on a pruned node = %v, want errHistoricalStateUnavailable", err)
+	}
+}
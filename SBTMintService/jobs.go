@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ジョブの状態
+const (
+	jobStatusQueued    = "queued"
+	jobStatusSubmitted = "submitted"
+	jobStatusConfirmed = "confirmed"
+	jobStatusFailed    = "failed"
+)
+
+// バケット名
+var (
+	jobsBucket        = []byte("jobs")
+	idempotencyBucket = []byte("idempotency") // Idempotency-Key → ジョブID
+	walletJobsBucket  = []byte("walletJobs")  // ウォレット(20バイト)+ジョブID → 空
+)
+
+var (
+	// errJobNotFound - 指定されたジョブが存在しない
+	errJobNotFound = errors.New("job not found")
+	// errIdempotencyMismatch - 同じIdempotency-Keyが異なる内容のリクエストで使われた
+	errIdempotencyMismatch = errors.New("idempotency key already used for a different request")
+	// errWalletLimitReached - ウォレットのMint上限に達している
+	errWalletLimitReached = errors.New("wallet has reached its mint limit")
+)
+
+// MintJob - ディスクに永続化されるMintジョブ
+type MintJob struct {
+	ID                string            `json:"id"`
+	WalletAddress     string            `json:"walletAddress"`
+	IdempotencyKey    string            `json:"idempotencyKey,omitempty"`
+	CredentialType    string            `json:"credentialType,omitempty"` // アテステーションで確認したVerified IDの種類
+	CorrelationID     string            `json:"correlationId,omitempty"`  // ジョブを作成したリクエストの X-Request-ID（ログの requestId）
+	TraceContext      map[string]string `json:"traceContext,omitempty"`   // ジョブを作成したリクエストのW3C Trace Context（処理のスパンの親）
+	Status            string            `json:"status"`
+	Signer            string            `json:"signer,omitempty"` // 署名した署名者のアドレス
+	TxHash            string            `json:"txHash,omitempty"`
+	RawTx             string            `json:"rawTx,omitempty"` // 再起動後の再送信用に署名済みトランザクションを保持
+	BroadcastAt       time.Time         `json:"broadcastAt,omitzero"`
+	ReplacedTxHashes  []string          `json:"replacedTxHashes,omitempty"` // 手数料を引き上げて置き換えた以前のトランザクション
+	TokenID           string            `json:"tokenId,omitempty"`
+	BlockNumber       uint64            `json:"blockNumber,omitempty"`
+	GasUsed           uint64            `json:"gasUsed,omitempty"`
+	EffectiveGasPrice string            `json:"effectiveGasPrice,omitempty"` // wei
+	FeePaid           string            `json:"feePaid,omitempty"`           // wei
+	Reverted          bool              `json:"reverted,omitempty"`
+	Error             string            `json:"error,omitempty"`
+	ErrorCode         string            `json:"errorCode,omitempty"`
+	ErrorParams       map[string]string `json:"errorParams,omitempty"`
+	CreatedAt         time.Time         `json:"createdAt"`
+	UpdatedAt         time.Time         `json:"updatedAt"`
+}
+
+// finished - ジョブが終了状態かどうか
+func (j *MintJob) finished() bool {
+	return j.Status == jobStatusConfirmed || j.Status == jobStatusFailed
+}
+
+// txHashes - 取り込まれる可能性のあるトランザクション（現在のものと置き換え前のもの）
+func (j *MintJob) txHashes() []common.Hash {
+	hashes := make([]common.Hash, 0, len(j.ReplacedTxHashes)+1)
+	if j.TxHash != "" {
+		hashes = append(hashes, common.HexToHash(j.TxHash))
+	}
+	for _, hash := range j.ReplacedTxHashes {
+		hashes = append(hashes, common.HexToHash(hash))
+	}
+	return hashes
+}
+
+// signerAddress - ジョブのトランザクションの署名者（記録が無い古いジョブは署名済みトランザクションから復元する）
+func (j *MintJob) signerAddress() (common.Address, bool) {
+	if j.Signer != "" {
+		return common.HexToAddress(j.Signer), true
+	}
+	tx, err := decodeRawTx(j.RawTx)
+	if err != nil {
+		return common.Address{}, false
+	}
+	from, err := txSender(tx)
+	return from, err == nil
+}
+
+// broadcastAt - 現在のトランザクションを送信した時刻（記録が無い古いジョブは更新時刻）
+func (j *MintJob) broadcastAt() time.Time {
+	if j.BroadcastAt.IsZero() {
+		return j.UpdatedAt
+	}
+	return j.BroadcastAt
+}
+
+// applyResult - レシートから得た結果をジョブに反映する
+func (j *MintJob) applyResult(result *MintResult) {
+	if result.TxHash != "" {
+		// 置き換え前のトランザクションが取り込まれた場合はそのハッシュにする
+		j.TxHash = result.TxHash
+	}
+	if result.TokenID != nil {
+		j.TokenID = result.TokenID.String()
+	}
+	j.BlockNumber = result.BlockNumber
+	j.GasUsed = result.GasUsed
+	if result.EffectiveGasPrice != nil {
+		j.EffectiveGasPrice = result.EffectiveGasPrice.String()
+	}
+	if result.FeePaid != nil {
+		j.FeePaid = result.FeePaid.String()
+	}
+}
+
+// fail - ジョブを失敗として記録し、エラーをエラーコードに分類する
+func (j *MintJob) fail(err error) {
+	j.Status = jobStatusFailed
+	j.Error = err.Error()
+	j.ErrorCode, j.ErrorParams = classifyMintError(err)
+}
+
+// response - MintJobをMintResponseに変換
+func (j *MintJob) response() MintResponse {
+	var message string
+	switch {
+	case j.Status == jobStatusQueued:
+		message = "SBT mint queued"
+	case j.Status == jobStatusSubmitted:
+		message = "SBT mint transaction submitted"
+	case j.Status == jobStatusConfirmed:
+		message = "SBT minted successfully"
+	case errorCodeMessage(j.ErrorCode) != "":
+		message = errorCodeMessage(j.ErrorCode)
+	case j.Reverted:
+		message = "SBT mint transaction reverted"
+	default:
+		message = "SBT mint failed"
+	}
+	return MintResponse{
+		Success:           j.Status != jobStatusFailed,
+		JobID:             j.ID,
+		Status:            j.Status,
+		TxHash:            j.TxHash,
+		ReplacedTxHashes:  j.ReplacedTxHashes,
+		TokenID:           j.TokenID,
+		BlockNumber:       j.BlockNumber,
+		GasUsed:           j.GasUsed,
+		EffectiveGasPrice: j.EffectiveGasPrice,
+		FeePaid:           j.FeePaid,
+		Error:             j.Error,
+		ErrorCode:         j.ErrorCode,
+		ErrorParams:       j.ErrorParams,
+		Message:           message,
+	}
+}
+
+// JobStore - BoltDBを使ったジョブストア
+type JobStore struct {
+	db *bolt.DB
+}
+
+// openJobStore - ジョブストアを開く（ファイルが無ければ作成）
+func openJobStore(path string) (*JobStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{jobsBucket, idempotencyBucket, batchesBucket, batchKeysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(walletJobsBucket) != nil {
+			return nil
+		}
+		// 索引が無い古いデータベースは既存のジョブから作成する
+		if _, err := tx.CreateBucket(walletJobsBucket); err != nil {
+			return err
+		}
+		return tx.Bucket(jobsBucket).ForEach(func(_, v []byte) error {
+			var job MintJob
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			return putWalletJob(tx, &job)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize job store: %w", err)
+	}
+	return &JobStore{db: db}, nil
+}
+
+// Close - ジョブストアを閉じる
+func (s *JobStore) Close() error {
+	return s.db.Close()
+}
+
+// CreateJob - 新しいジョブを保存する
+// Idempotency-Keyに対応するジョブが既にあればそれを返す（2つ目の戻り値がfalse）
+// maxPending が0以上の場合、同じウォレットの未完了ジョブがその数に達していれば
+// そのジョブと errWalletLimitReached を返す
+func (s *JobStore) CreateJob(job *MintJob, maxPending int) (*MintJob, bool, error) {
+	var existing *MintJob
+	var created bool
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		existing, created, err = createJob(tx, job, maxPending)
+		return err
+	})
+	return existing, created, err
+}
+
+// createJob - トランザクション内でジョブを保存する（CreateJob と同じ扱い）
+// errWalletLimitReached・errIdempotencyMismatch の場合は何も書き込まない
+func createJob(tx *bolt.Tx, job *MintJob, maxPending int) (*MintJob, bool, error) {
+	keys := tx.Bucket(idempotencyBucket)
+	if job.IdempotencyKey != "" {
+		if id := keys.Get([]byte(job.IdempotencyKey)); id != nil {
+			existing, err := getJob(tx, string(id))
+			if err != nil {
+				return nil, false, err
+			}
+			if !strings.EqualFold(existing.WalletAddress, job.WalletAddress) {
+				return existing, false, errIdempotencyMismatch
+			}
+			return existing, false, nil
+		}
+	}
+
+	if maxPending >= 0 {
+		pending, err := unfinishedJobsFor(tx, job.WalletAddress)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(pending) >= maxPending {
+			if len(pending) > 0 {
+				return pending[0], false, errWalletLimitReached
+			}
+			return nil, false, errWalletLimitReached
+		}
+	}
+
+	if job.IdempotencyKey != "" {
+		if err := keys.Put([]byte(job.IdempotencyKey), []byte(job.ID)); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := putJob(tx, job); err != nil {
+		return nil, false, err
+	}
+	return job, true, nil
+}
+
+// LookupIdempotencyKey - Idempotency-Keyに対応するジョブを返す（無ければnil）
+func (s *JobStore) LookupIdempotencyKey(key string) (*MintJob, error) {
+	var job *MintJob
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(idempotencyBucket).Get([]byte(key))
+		if id == nil {
+			return nil
+		}
+		var err error
+		job, err = getJob(tx, string(id))
+		return err
+	})
+	return job, err
+}
+
+// ConfirmedTokenID - ウォレットに対して確定済みのジョブがあればそのトークンIDを返す
+func (s *JobStore) ConfirmedTokenID(walletAddress string) (string, error) {
+	var tokenID string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		jobs, err := jobsFor(tx, walletAddress)
+		for _, job := range jobs {
+			if job.Status == jobStatusConfirmed {
+				tokenID = job.TokenID
+			}
+		}
+		return err
+	})
+	return tokenID, err
+}
+
+// Get - ジョブを取得
+func (s *JobStore) Get(id string) (*MintJob, error) {
+	var job *MintJob
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		job, err = getJob(tx, id)
+		return err
+	})
+	return job, err
+}
+
+// Update - ジョブを読み込み、fnで変更して保存する
+func (s *JobStore) Update(id string, fn func(*MintJob)) (*MintJob, error) {
+	var job *MintJob
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		job, err = getJob(tx, id)
+		if err != nil {
+			return err
+		}
+		fn(job)
+		job.UpdatedAt = time.Now().UTC()
+		return putJob(tx, job)
+	})
+	return job, err
+}
+
+// Unfinished - 終了していないジョブを作成順に返す
+func (s *JobStore) Unfinished() ([]*MintJob, error) {
+	var jobs []*MintJob
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(_, v []byte) error {
+			var job MintJob
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			if !job.finished() {
+				jobs = append(jobs, &job)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].CreatedAt.Before(jobs[k].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// unfinishedJobsFor - トランザクション内でウォレットの未完了ジョブを探す
+func unfinishedJobsFor(tx *bolt.Tx, walletAddress string) ([]*MintJob, error) {
+	jobs, err := jobsFor(tx, walletAddress)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(jobs, (*MintJob).finished), nil
+}
+
+// jobsFor - トランザクション内でウォレットの索引からジョブを読み込む
+func jobsFor(tx *bolt.Tx, walletAddress string) ([]*MintJob, error) {
+	var jobs []*MintJob
+	c := tx.Bucket(walletJobsBucket).Cursor()
+	prefix := common.HexToAddress(walletAddress).Bytes()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		job, err := getJob(tx, string(k[len(prefix):]))
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// getJob - トランザクション内でジョブを読み込む
+func getJob(tx *bolt.Tx, id string) (*MintJob, error) {
+	data := tx.Bucket(jobsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, errJobNotFound
+	}
+	var job MintJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job %s: %w", id, err)
+	}
+	return &job, nil
+}
+
+// putJob - トランザクション内でジョブを書き込む
+func putJob(tx *bolt.Tx, job *MintJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(jobsBucket).Put([]byte(job.ID), data); err != nil {
+		return err
+	}
+	return putWalletJob(tx, job)
+}
+
+// putWalletJob - トランザクション内でウォレットの索引にジョブを登録する
+func putWalletJob(tx *bolt.Tx, job *MintJob) error {
+	key := append(common.HexToAddress(job.WalletAddress).Bytes(), job.ID...)
+	return tx.Bucket(walletJobsBucket).Put(key, nil)
+}
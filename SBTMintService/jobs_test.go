@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestStore - テストごとの一時ディレクトリにジョブストアを作る
+func openTestStore(t *testing.T) *JobStore {
+	t.Helper()
+	store, err := openJobStore(filepath.Join(t.TempDir(), "mint.db"))
+	if err != nil {
+		t.Fatalf("openJobStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// testJob - キューに入った状態のジョブ
+func testJob(id, walletAddress string, createdAt time.Time) *MintJob {
+	return &MintJob{
+		ID:            id,
+		WalletAddress: walletAddress,
+		Status:        jobStatusQueued,
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+	}
+}
+
+// TestJobStore - 保存・更新と、再起動時に再開する未完了ジョブの順序
+func TestJobStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mint.db")
+	store, err := openJobStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wallet = "0x1111111111111111111111111111111111111111"
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 作成順とIDの順序が異なるように保存する
+	for i, id := range []string{"c", "a", "b"} {
+		if _, _, err := store.CreateJob(testJob(id, wallet, base.Add(time.Duration(i)*time.Second)), -1); err != nil {
+			t.Fatalf("CreateJob(%s): %v", id, err)
+		}
+	}
+	if _, err := store.Update("a", func(j *MintJob) {
+		j.Status = jobStatusConfirmed
+		j.TokenID = "7"
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := store.Update("missing", func(*MintJob) {}); !errors.Is(err, errJobNotFound) {
+		t.Errorf("Update(missing) = %v, want errJobNotFound", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 再起動後も残っている
+	store, err = openJobStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	job, err := store.Get("a")
+	if err != nil || job.Status != jobStatusConfirmed || job.TokenID != "7" {
+		t.Errorf("Get(a) = %+v, %v, want confirmed token 7", job, err)
+	}
+	if _, err := store.Get("missing"); !errors.Is(err, errJobNotFound) {
+		t.Errorf("Get(missing) = %v, want errJobNotFound", err)
+	}
+
+	jobs, err := store.Unfinished()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "c" || jobs[1].ID != "b" {
+		ids := make([]string, len(jobs))
+		for i, j := range jobs {
+			ids[i] = j.ID
+		}
+		t.Errorf("Unfinished = %v, want [c b]", ids)
+	}
+}
+
+// TestCreateJobIdempotency - 同じIdempotency-Keyの再送は元のジョブを返し、別のウォレットには使えない
+func TestCreateJobIdempotency(t *testing.T) {
+	store := openTestStore(t)
+	const wallet = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	keyed := func(id, walletAddress, key string) *MintJob {
+		job := testJob(id, walletAddress, now)
+		job.IdempotencyKey = key
+		return job
+	}
+
+	tests := []struct {
+		name        string
+		job         *MintJob
+		wantID      string
+		wantCreated bool
+		wantErr     error
+	}{
+		{name: "new key", job: keyed("a", wallet, "key-1"), wantID: "a", wantCreated: true},
+		{name: "retry", job: keyed("b", wallet, "key-1"), wantID: "a"},
+		{name: "retry with other address case", job: keyed("c", "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "key-1"), wantID: "a"},
+		{name: "reused for another wallet", job: keyed("d", "0x2222222222222222222222222222222222222222", "key-1"), wantID: "a", wantErr: errIdempotencyMismatch},
+		{name: "without key", job: keyed("e", wallet, ""), wantID: "e", wantCreated: true},
+		{name: "another key", job: keyed("f", wallet, "key-2"), wantID: "f", wantCreated: true},
+	}
+	for _, tt := range tests {
+		job, created, err := store.CreateJob(tt.job, -1)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if job == nil || job.ID != tt.wantID || created != tt.wantCreated {
+			t.Errorf("%s: CreateJob = %v, %v, want job %s, created %v", tt.name, job, created, tt.wantID, tt.wantCreated)
+		}
+	}
+	for _, id := range []string{"b", "c", "d"} {
+		if _, err := store.Get(id); !errors.Is(err, errJobNotFound) {
+			t.Errorf("Get(%s) = %v, want the replayed job not to be stored", id, err)
+		}
+	}
+}
+
+// TestCreateJobWalletLimit - ウォレットごとの未完了ジョブ数の上限と確定済みのトークンID
+func TestCreateJobWalletLimit(t *testing.T) {
+	store := openTestStore(t)
+	const wallet = "0x1111111111111111111111111111111111111111"
+	const other = "0x2222222222222222222222222222222222222222"
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, created, err := store.CreateJob(testJob("a", wallet, now), 1); err != nil || !created {
+		t.Fatalf("first CreateJob = %v, %v, want created", created, err)
+	}
+
+	// 同じウォレットの2件目は上限に達している
+	pending, created, err := store.CreateJob(testJob("b", wallet, now), 1)
+	if !errors.Is(err, errWalletLimitReached) || created {
+		t.Fatalf("second CreateJob = %v, %v, want errWalletLimitReached", created, err)
+	}
+	if pending == nil || pending.ID != "a" {
+		t.Errorf("second CreateJob returned %v, want pending job a", pending)
+	}
+	if _, created, err := store.CreateJob(testJob("c", other, now), 1); err != nil || !created {
+		t.Errorf("CreateJob for another wallet = %v, %v, want created", created, err)
+	}
+
+	// 確定すれば次のジョブを登録でき、確定済みのトークンIDを返す
+	if _, err := store.Update("a", func(j *MintJob) {
+		j.Status = jobStatusConfirmed
+		j.TokenID = "7"
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, created, err := store.CreateJob(testJob("b", wallet, now), 1); err != nil || !created {
+		t.Errorf("CreateJob after confirmation = %v, %v, want created", created, err)
+	}
+	tokenID, err := store.ConfirmedTokenID(wallet)
+	if err != nil || tokenID != "7" {
+		t.Errorf("ConfirmedTokenID = %q, %v, want 7", tokenID, err)
+	}
+	if tokenID, err := store.ConfirmedTokenID(other); err != nil || tokenID != "" {
+		t.Errorf("ConfirmedTokenID(other) = %q, %v, want empty", tokenID, err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// requestIDHeader - リクエストを追跡するIDのヘッダー（C#バックエンドのログと突き合わせる）
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength - 受け付ける X-Request-ID の最大長（超える場合は新しく生成する）
+const maxRequestIDLength = 128
+
+// loggerKey - context に入れる *slog.Logger のキー
+type loggerKey struct{}
+
+// requestIDKey - context に入れるリクエストIDのキー
+type requestIDKey struct{}
+
+// setupLogging - 構造化ログの出力形式（json / text）とレベル（debug / info / warn / error）を設定する
+// log パッケージの出力も同じハンドラーに流す
+func setupLogging(format, level string) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	if format == "text" {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// fatal - エラーを記録して終了する
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// withLogger - ロガーを context に入れる
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom - context のロガー（無ければ既定のロガー）
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// requestIDFrom - context のロガーに付けたリクエストID（無ければ空）
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID - X-Request-ID（無い・不正なら生成）をレスポンスヘッダーに返し、
+// リクエストIDを付けたロガーを context に入れて、処理後にアクセスログを出す
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get(requestIDHeader))
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := slog.Default().With("requestId", id)
+		ctx := context.WithValue(withLogger(r.Context(), logger), requestIDKey{}, id)
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		// ヘルスチェックは頻繁に呼ばれるのでデバッグレベルにする
+		level := slog.LevelInfo
+		if strings.HasPrefix(r.URL.Path, "/health") || r.URL.Path == "/readyz" {
+			level = slog.LevelDebug
+		}
+		logger.Log(ctx, level, "HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration", time.Since(start).Round(time.Millisecond).String())
+	})
+}
+
+// validRequestID - ログに出しても安全な長さ・文字のIDか
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// jobLogger - ジョブを作成したリクエストのIDとジョブの情報を付けたロガー
+func jobLogger(job *MintJob) *slog.Logger {
+	logger := slog.Default().With("jobId", job.ID, "wallet", job.WalletAddress)
+	if job.CorrelationID != "" {
+		logger = logger.With("requestId", job.CorrelationID)
+	}
+	return logger
+}
@@ -2,20 +2,20 @@ package main
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"math/big"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/joho/godotenv"
 )
 
@@ -24,26 +24,88 @@ var (
 	rpcURL          string
 	contractAddress string
 	chainID         *big.Int
-	privateKey      string
+	receiptTimeout  time.Duration
+	dbPath          string
+	mintPolicy      MintPolicy
+	authDisabled    bool
+	attestations    *AttestationVerifier
+	siwe            *SIWEVerifier
+)
+
+var (
+	// mintQueue - 非同期Mintジョブのキュー
+	mintQueue *MintQueue
+	// minter - RPC接続と署名鍵を保持するMint処理コンポーネント
+	minter *Minter
+	// tokenIndex - Transferイベントから作るトークン保有者のレジストリ
+	tokenIndex *TokenIndex
+	// responseCache - 参照系エンドポイントの短期キャッシュ
+	responseCache *queryCache
+	// apiKeys - /mint の呼び出し元を認証するAPIキー
+	apiKeys *APIKeyRing
+	// rateLimiter - /mint の呼び出し元・送信元IP・受取ウォレットごとのレート制限
+	rateLimiter *RateLimiter
+	// readOnlyReason - 起動時の検証に失敗して読み取り専用モードで起動した理由（空なら通常モード）
+	readOnlyReason string
+	// batchMaxRecipients - /mint/batch の1リクエストあたりの宛先数の上限
+	batchMaxRecipients int
 )
 
 // MintRequest - HTTPリクエストのペイロード
 type MintRequest struct {
 	WalletAddress string `json:"walletAddress"`
+	// RequestID - 再試行時の二重Mintを防ぐためのキー（Idempotency-Keyヘッダーと同じ扱い）
+	RequestID string `json:"requestId,omitempty"`
+	// Wait - trueの場合はレシートを待ってから応答する
+	Wait bool `json:"wait,omitempty"`
+	// Attestation - C#バックエンドがVerified IDの提示を検証した証明（ES256のJWS）
+	Attestation string `json:"attestation,omitempty"`
+	// SIWE - 受取ウォレットの所有証明（EIP-4361のメッセージと署名）
+	SIWE *SIWEProof `json:"siwe,omitempty"`
 }
 
+// maxIdempotencyKeyLength - Idempotency-Keyの最大長
+const maxIdempotencyKeyLength = 255
+
 // MintResponse - HTTPレスポンス
 type MintResponse struct {
 	Success bool   `json:"success"`
+	JobID   string `json:"jobId,omitempty"`
+	Status  string `json:"status,omitempty"`
 	TxHash  string `json:"txHash,omitempty"`
-	Message string `json:"message"`
+	// ReplacedTxHashes - 手数料を引き上げて置き換えた以前のトランザクション
+	ReplacedTxHashes []string `json:"replacedTxHashes,omitempty"`
+	TokenID          string   `json:"tokenId,omitempty"`
+	BlockNumber      uint64   `json:"blockNumber,omitempty"`
+	GasUsed          uint64   `json:"gasUsed,omitempty"`
+	// EffectiveGasPrice / FeePaid - 実際に支払ったガス単価と手数料（wei）
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	FeePaid           string `json:"feePaid,omitempty"`
+	Error             string `json:"error,omitempty"`
+	// ErrorCode / ErrorParams - 失敗理由の安定したコードと、カスタムエラーの引数
+	ErrorCode   string            `json:"errorCode,omitempty"`
+	ErrorParams map[string]string `json:"errorParams,omitempty"`
+	Message     string            `json:"message"`
+}
+
+// HealthResponse - ヘルスチェックのレスポンス
+type HealthResponse struct {
+	Status  string         `json:"status"`
+	RPC     MinterHealth   `json:"rpc"`
+	Indexer IndexerStatus  `json:"indexer"`
+	Signers []SignerStatus `json:"signers"`
+	Funds   FundsStatus    `json:"funds"`
 }
 
 func main() {
 	// .envファイルを読み込む（親ディレクトリから）
 	envPath := filepath.Join("..", ".env")
-	if err := godotenv.Load(envPath); err != nil {
-		log.Printf("Warning: .env file not found at %s, using system environment variables", envPath)
+	envErr := godotenv.Load(envPath)
+
+	// 構造化ログ（MINT_LOG_FORMAT=json / text、MINT_LOG_LEVEL=debug / info / warn / error）
+	setupLogging(getEnv("MINT_LOG_FORMAT", "json"), getEnv("MINT_LOG_LEVEL", "info"))
+	if envErr != nil {
+		slog.Warn(".env file not found, using system environment variables", "path", envPath)
 	}
 
 	// 環境変数から設定を読み込む
@@ -51,24 +113,257 @@ func main() {
 	contractAddress = getEnv("BLOCKCHAIN_CONTRACT_ADDRESS", "0xFF49Af5D03DA6E855F97cE19384AE13086A32e0c")
 	chainIDInt := getEnvAsInt("BLOCKCHAIN_CHAIN_ID", 80002)
 	chainID = big.NewInt(chainIDInt)
-	privateKey = os.Getenv("PRIVATE_KEY")
+	receiptTimeout = time.Duration(getEnvAsInt("MINT_RECEIPT_TIMEOUT_SECONDS", 120)) * time.Second
+	dbPath = getEnv("MINT_DB_PATH", "mint.db")
+	batchMaxRecipients = int(getEnvAsInt("MINT_BATCH_MAX_RECIPIENTS", 500))
+	workers := int(getEnvAsInt("MINT_WORKERS", 4))
+	nonceInterval := getEnvAsInterval("MINT_NONCE_RECONCILE_SECONDS", 60)
+	nonceGapTimeout := time.Duration(getEnvAsInt("MINT_NONCE_GAP_TIMEOUT_SECONDS", 120)) * time.Second
+	rpcHealthInterval := getEnvAsInterval("MINT_RPC_HEALTH_INTERVAL_SECONDS", 30)
+	indexerConfig := IndexerConfig{
+		Confirmations: uint64(getEnvAsInt("MINT_INDEXER_CONFIRMATIONS", 5)),
+		BatchSize:     uint64(getEnvAsInt("MINT_INDEXER_BATCH_BLOCKS", 2000)),
+		Interval:      getEnvAsInterval("MINT_INDEXER_INTERVAL_SECONDS", 15),
+	}
+	// 開始ブロックは明示的に指定する（auto の場合のみデプロイブロックをチェーンから探す）
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("MINT_INDEXER_START_BLOCK")), "auto") {
+		indexerConfig.DetectStartBlock = true
+	} else {
+		indexerConfig.StartBlock = uint64(getEnvAsInt("MINT_INDEXER_START_BLOCK", 0))
+	}
+	queryCacheTTL := time.Duration(getEnvAsInt("MINT_QUERY_CACHE_SECONDS", 10)) * time.Second
+	queryCacheEntries := int(getEnvAsInt("MINT_QUERY_CACHE_ENTRIES", 10000))
+	stuck := StuckConfig{
+		Timeout:     time.Duration(getEnvAsInt("MINT_STUCK_TIMEOUT_SECONDS", 180)) * time.Second,
+		BumpPercent: getEnvAsInt("MINT_FEE_BUMP_PERCENT", 15),
+		MaxBumps:    int(getEnvAsInt("MINT_MAX_FEE_BUMPS", 5)),
+	}
+	balanceConfig := BalanceConfig{
+		Interval:       getEnvAsInterval("MINT_BALANCE_CHECK_SECONDS", 60),
+		WarnThresholds: getEnvAsPOLList("MINT_BALANCE_WARN_POL"),
+		WebhookURL:     os.Getenv("MINT_ALERT_WEBHOOK_URL"),
+		MintGas:        uint64(getEnvAsInt("MINT_GAS_PER_MINT", 150000)),
+	}
+	metricsAddr := getEnv("MINT_METRICS_ADDR", ":9091")
+	startupCheck := getEnv("MINT_STARTUP_CHECK", startupCheckFail)
+	startupTimeout := time.Duration(getEnvAsInt("MINT_STARTUP_CHECK_TIMEOUT_SECONDS", 30)) * time.Second
+	policy, err := parseMintPolicy(os.Getenv("MINT_POLICY"))
+	if err != nil {
+		fatal("Startup failed", "error", err)
+	}
+	mintPolicy = policy
+
+	// APIキーの読み込み（キーが無い場合は MINT_AUTH_DISABLED=true の時だけ起動する）
+	authDisabled = getEnv("MINT_AUTH_DISABLED", "false") == "true"
+	apiKeys, err = newAPIKeyRing(os.Getenv("MINT_API_KEYS"), os.Getenv("MINT_API_KEYS_FILE"))
+	if err != nil {
+		fatal("Startup failed", "error", err)
+	}
+	if apiKeys.Len() == 0 && !authDisabled {
+		fatal("No API keys configured: set MINT_API_KEYS or MINT_API_KEYS_FILE (or MINT_AUTH_DISABLED=true for local development)")
+	}
+	attestations, err = newAttestationVerifier(AttestationConfig{
+		JWKS:              os.Getenv("MINT_ATTESTATION_JWKS"),
+		Issuer:            os.Getenv("MINT_ATTESTATION_ISSUER"),
+		CredentialTypes:   splitList(os.Getenv("MINT_ATTESTATION_CREDENTIAL_TYPES")),
+		CredentialIssuers: splitList(os.Getenv("MINT_ATTESTATION_CREDENTIAL_ISSUERS")),
+		Required:          getEnv("MINT_ATTESTATION_REQUIRED", "false") == "true",
+	})
+	if err != nil {
+		fatal("Startup failed", "error", err)
+	}
+
+	rateLimitConfig := RateLimitConfig{ClientIPHeader: os.Getenv("MINT_CLIENT_IP_HEADER")}
+	for _, limit := range []struct {
+		key, defaultValue string
+		dst               *RateLimit
+	}{
+		{"MINT_RATE_LIMIT_CLIENT", "60/m", &rateLimitConfig.Client},
+		{"MINT_RATE_LIMIT_IP", "20/m", &rateLimitConfig.IP},
+		{"MINT_RATE_LIMIT_WALLET", "5/h", &rateLimitConfig.Wallet},
+		{"MINT_RATE_LIMIT_SIWE_NONCE", "30/m", &rateLimitConfig.Nonce},
+	} {
+		if *limit.dst, err = parseRateLimit(getEnv(limit.key, limit.defaultValue)); err != nil {
+			fatal("Invalid rate limit", "key", limit.key, "error", err)
+		}
+	}
+
+	siweConfig := SIWEConfig{
+		Domains:  splitList(os.Getenv("MINT_SIWE_DOMAINS")),
+		NonceTTL: time.Duration(getEnvAsInt("MINT_SIWE_NONCE_TTL_SECONDS", 900)) * time.Second,
+		Required: getEnv("MINT_SIWE_REQUIRED", "false") == "true",
+	}
+
+	// 署名者（MINT_SIGNER=env / keystore / remote、コントラクトの所有者1つだけ）
+	signerKind := getEnv("MINT_SIGNER", signerKindEnv)
+	signers, err := newSigners(SignerConfig{
+		Kind:                 signerKind,
+		PrivateKeys:          splitList(os.Getenv("PRIVATE_KEY")),
+		KeystoreFiles:        splitList(os.Getenv("MINT_KEYSTORE_FILE")),
+		KeystorePassword:     os.Getenv("MINT_KEYSTORE_PASSWORD"),
+		KeystorePasswordFile: os.Getenv("MINT_KEYSTORE_PASSWORD_FILE"),
+		RemoteURL:            os.Getenv("MINT_REMOTE_SIGNER_URL"),
+		RemoteAddresses:      splitList(os.Getenv("MINT_REMOTE_SIGNER_ADDRESS")),
+		RemoteMethod:         os.Getenv("MINT_REMOTE_SIGNER_METHOD"),
+	})
+	if err != nil {
+		fatal("Startup failed", "error", err)
+	}
+
+	// RPC接続・署名者のプール・コントラクトを保持するMinterを作成
+	minter, err = newMinter(MinterConfig{
+		RPCURL:           rpcURL,
+		ContractAddress:  common.HexToAddress(contractAddress),
+		ChainID:          chainID,
+		Signers:          signers,
+		MinSignerBalance: getEnvAsPOL("MINT_SIGNER_MIN_BALANCE_POL"),
+		Fees: FeeConfig{
+			MaxFeePerGas:         getEnvAsGwei("MINT_MAX_FEE_GWEI"),
+			MaxPriorityFeePerGas: getEnvAsGwei("MINT_MAX_PRIORITY_FEE_GWEI"),
+		},
+		Gas: GasConfig{
+			Multiplier: getEnvAsFloat("MINT_GAS_MULTIPLIER", 1.2),
+			MaxGas:     uint64(getEnvAsInt("MINT_GAS_LIMIT_MAX", 500000)),
+		},
+	})
+	if err != nil {
+		fatal("Startup failed", "error", err)
+	}
+	defer minter.Close()
+
+	signerAddresses := make([]string, 0, minter.pool.Len())
+	for _, address := range minter.pool.Addresses() {
+		signerAddresses = append(signerAddresses, address.Hex())
+	}
+	minBalance := "none"
+	if minter.pool.minBalance != nil {
+		minBalance = formatPOL(minter.pool.minBalance)
+	}
+	warnAt := make([]string, len(balanceConfig.WarnThresholds))
+	for i, threshold := range balanceConfig.WarnThresholds {
+		warnAt[i] = formatPOL(threshold)
+	}
+	slog.Info("Configuration loaded",
+		"rpcUrl", rpcURL,
+		"contractAddress", contractAddress,
+		"chainId", chainID.Int64(),
+		slog.Group("signers", "kind", signerKind, "addresses", signerAddresses,
+			"minBalance", minBalance, "warnAt", warnAt, "balanceCheckInterval", balanceConfig.Interval.String(),
+			"alertWebhook", balanceConfig.WebhookURL != ""),
+		"receiptTimeout", receiptTimeout.String(),
+		"jobStore", dbPath,
+		"mintPolicy", mintPolicy.String(),
+		"batchMaxRecipients", batchMaxRecipients,
+		slog.Group("fees", "maxFee", formatFeeCap(minter.fees.MaxFeePerGas),
+			"maxPriorityFee", formatFeeCap(minter.fees.MaxPriorityFeePerGas),
+			"gasMultiplier", minter.gas.Multiplier, "maxGas", minter.gas.MaxGas),
+		slog.Group("indexer", "startBlock", indexerConfig.StartBlock, "detectStartBlock", indexerConfig.DetectStartBlock,
+			"confirmations", indexerConfig.Confirmations),
+		slog.Group("auth", "disabled", authDisabled, "apiKeys", apiKeys.Len()),
+		slog.Group("attestation", "required", attestations.Required(), "keys", attestations.Len()),
+		slog.Group("rateLimits", "client", rateLimitConfig.Client.String(), "ip", rateLimitConfig.IP.String(),
+			"wallet", rateLimitConfig.Wallet.String(), "siweNonce", rateLimitConfig.Nonce.String()),
+		slog.Group("siwe", "required", siweConfig.Required, "domains", siweConfig.Domains),
+		slog.Group("stuck", "timeout", stuck.Timeout.String(), "feeBumpPercent", stuck.BumpPercent, "maxBumps", stuck.MaxBumps),
+	)
+
+	// トレースの送信（OTEL_EXPORTER_OTLP_ENDPOINT が無ければ traceparent の伝播のみ）
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// 全ての署名者がMintできること等を確認する（失敗時は終了するか読み取り専用モードで起動）
+	readOnlyReason = minter.validateStartup(startupCheck, startupTimeout)
+
+	// ジョブストアを開き、未完了のジョブを再開する
+	store, err := openJobStore(dbPath)
+	if err != nil {
+		fatal("Startup failed", "error", err)
+	}
+	defer store.Close()
+
+	siwe, err = newSIWEVerifier(store.db, chainID.Int64(), siweConfig)
+	if err != nil {
+		fatal("Startup failed", "error", err)
+	}
+
+	rateLimiter, err = newRateLimiter(store.db, rateLimitConfig)
+	if err != nil {
+		fatal("Startup failed", "error", err)
+	}
+	go rateLimiter.pruneLoop(10 * time.Minute)
+	go siwe.pruneLoop(10 * time.Minute)
+
+	// 排出中の署名者の状態を読み込む（MINT_DRAIN_SIGNERS の署名者は起動時に排出中にする）
+	if err := minter.pool.attachStore(store.db); err != nil {
+		fatal("Failed to load signer state", "error", err)
+	}
+	for _, address := range splitList(os.Getenv("MINT_DRAIN_SIGNERS")) {
+		if err := minter.pool.SetDraining(common.HexToAddress(address), true); err != nil {
+			fatal("Startup failed", "error", err)
+		}
+	}
 
-	if privateKey == "" {
-		log.Fatal("PRIVATE_KEY environment variable is not set")
+	// 読み取り専用モードでは送信済みジョブの結果の追跡だけを行い、キュー内のジョブは送信しない
+	mintQueue = newMintQueue(store, minter)
+	if readOnlyReason != "" {
+		workers = 0
+	}
+	if err := mintQueue.Start(workers); err != nil {
+		fatal("Failed to resume mint jobs", "error", err)
 	}
+	if readOnlyReason == "" {
+		go mintQueue.watchNonces(nonceInterval, nonceGapTimeout, stuck)
+	}
+	go minter.watchHealth(rpcHealthInterval)
+	go newBalanceMonitor(minter, balanceConfig).watch()
 
-	log.Printf("Configuration loaded:")
-	log.Printf("  RPC URL: %s", rpcURL)
-	log.Printf("  Contract Address: %s", contractAddress)
-	log.Printf("  Chain ID: %d", chainID.Int64())
+	// Transferイベントのインデックスを開始（StartBlock からバックフィル）
+	if !indexerConfig.DetectStartBlock && indexerConfig.StartBlock == 0 {
+		slog.Warn("MINT_INDEXER_START_BLOCK is not set, backfilling Transfer events from genesis; set it to the contract deploy block")
+	}
+	tokenIndex, err = newTokenIndex(store.db, minter, indexerConfig)
+	if err != nil {
+		fatal("Startup failed", "error", err)
+	}
+	go tokenIndex.watchTransfers()
+	responseCache = newQueryCache(queryCacheTTL, queryCacheEntries)
+	go apiKeys.watchFile(30 * time.Second)
+
+	// メトリクスは公開しない別のポートで提供する（fly.ioでは [metrics] で収集）
+	if metricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metricsHandler())
+			slog.Info("Metrics listening", "addr", metricsAddr)
+			if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+				fatal("Metrics server stopped", "error", err)
+			}
+		}()
+	}
 
 	// HTTPサーバーの起動
-	http.HandleFunc("/mint", mintHandler)
-	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/mint", instrumentHandler("/mint", instrumentMint(requireScope(scopeMint, mintHandler))))
+	http.HandleFunc("/mint/batch", instrumentHandler("/mint/batch", requireScope(scopeMintBatch, mintBatchHandler)))
+	http.HandleFunc("/mint/batch/{id}", instrumentHandler("/mint/batch/{id}", requireScope(scopeMintRead, mintBatchStatusHandler)))
+	http.HandleFunc("/mint/{id}", instrumentHandler("/mint/{id}", requireScope(scopeMintRead, mintStatusHandler)))
+	http.HandleFunc("/tokens/{id}", instrumentHandler("/tokens/{id}", tokenHandler))
+	http.HandleFunc("/wallets/{address}/tokens", instrumentHandler("/wallets/{address}/tokens", walletTokensHandler))
+	http.HandleFunc("/contract", instrumentHandler("/contract", contractHandler))
+	http.HandleFunc("/siwe/nonce", instrumentHandler("/siwe/nonce", siweNonceHandler))
+	http.HandleFunc("/signers", instrumentHandler("/signers", requireScope(scopeAdmin, signersHandler)))
+	http.HandleFunc("/signers/{address}/drain", instrumentHandler("/signers/{address}/drain", requireScope(scopeAdmin, drainSignerHandler)))
+	http.HandleFunc("/health", instrumentHandler("/health", healthHandler))
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", instrumentHandler("/readyz", readyzHandler))
 
 	port := "8080"
-	log.Printf("SBT Mint Service starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	slog.Info("SBT Mint Service starting", "port", port)
+	if err := http.ListenAndServe(":"+port, withRequestID(http.DefaultServeMux)); err != nil {
+		fatal("HTTP server stopped", "error", err)
+	}
 }
 
 // healthHandler - ヘルスチェック
@@ -84,8 +379,25 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// RPC接続に連続して失敗している場合・Mintできる残高の署名者がいない場合は degraded とする
+	rpcHealth := minter.Health()
+	funds := minter.pool.Funds()
+	status := "running"
+	if !rpcHealth.Healthy || !funds.Funded {
+		status = "degraded"
+	}
+	if readOnlyReason != "" {
+		status = "readonly"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "running"})
+	json.NewEncoder(w).Encode(HealthResponse{
+		Status:  status,
+		RPC:     rpcHealth,
+		Indexer: tokenIndex.Status(),
+		Signers: minter.pool.Status(),
+		Funds:   funds,
+	})
 }
 
 // mintHandler - SBT Mintエンドポイント
@@ -93,7 +405,8 @@ func mintHandler(w http.ResponseWriter, r *http.Request) {
 	// CORSヘッダーを設定
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Request-ID, traceparent, tracestate")
+	w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-Request-ID")
 
 	// OPTIONSリクエスト（プリフライト）の処理
 	if r.Method == http.MethodOptions {
@@ -112,13 +425,25 @@ func mintHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 読み取り専用モードではMintを受け付けない
+	if readOnlyReason != "" {
+		w.WriteHeader(errorCodeStatus(errCodeMintDisabled))
+		json.NewEncoder(w).Encode(MintResponse{
+			Success:   false,
+			ErrorCode: errCodeMintDisabled,
+			Message:   errorCodeMessage(errCodeMintDisabled),
+		})
+		return
+	}
+
 	// リクエストボディの解析
 	var req MintRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(MintResponse{
-			Success: false,
-			Message: "Invalid request body",
+			Success:   false,
+			ErrorCode: errCodeInvalidRequest,
+			Message:   "Invalid request body",
 		})
 		return
 	}
@@ -127,95 +452,308 @@ func mintHandler(w http.ResponseWriter, r *http.Request) {
 	if !common.IsHexAddress(req.WalletAddress) {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(MintResponse{
-			Success: false,
-			Message: "Invalid wallet address",
+			Success:   false,
+			ErrorCode: errCodeInvalidWallet,
+			Message:   "Invalid wallet address",
+		})
+		return
+	}
+
+	// このリクエストのログには受取ウォレットと呼び出し元を付ける
+	logger := loggerFrom(r.Context()).With("wallet", req.WalletAddress, "client", clientID(r))
+
+	// Idempotency-Keyヘッダーが無ければ requestId を使う
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if idempotencyKey == "" {
+		idempotencyKey = strings.TrimSpace(req.RequestID)
+	}
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(MintResponse{
+			Success:   false,
+			ErrorCode: errCodeInvalidRequest,
+			Message:   "Idempotency key is too long",
 		})
 		return
 	}
 
-	// SBTのMint実行
-	txHash, err := mintSBT(req.WalletAddress)
+	// Verified IDの検証結果の確認（ウォレットとrequestIdがアテステーションと一致すること）
+	var credentialType string
+	if req.Attestation != "" || attestations.Required() {
+		claims, err := attestations.Verify(req.Attestation, time.Now())
+		if err != nil {
+			logger.Warn("Rejected mint: invalid attestation", "error", err)
+			code := errCodeInvalidAttestation
+			if errors.Is(err, errAttestationRequired) {
+				code = errCodeAttestationRequired
+			}
+			w.WriteHeader(errorCodeStatus(code))
+			json.NewEncoder(w).Encode(MintResponse{
+				Success:   false,
+				ErrorCode: code,
+				Message:   errorCodeMessage(code),
+			})
+			return
+		}
+		if idempotencyKey == "" {
+			idempotencyKey = claims.RequestID
+		}
+		if !strings.EqualFold(claims.WalletAddress, req.WalletAddress) || claims.RequestID != idempotencyKey {
+			logger.Warn("Rejected mint: attestation mismatch",
+				"attestationWallet", claims.WalletAddress, "attestationRequestId", claims.RequestID)
+			w.WriteHeader(errorCodeStatus(errCodeAttestationMismatch))
+			json.NewEncoder(w).Encode(MintResponse{
+				Success:   false,
+				ErrorCode: errCodeAttestationMismatch,
+				Message:   errorCodeMessage(errCodeAttestationMismatch),
+			})
+			return
+		}
+		credentialType = claims.CredentialType
+	}
+
+	// 受取ウォレットの所有証明（SIWE）の確認（署名者が walletAddress であること）
+	if req.SIWE != nil || siwe.Required() {
+		if _, err := siwe.Verify(req.SIWE, common.HexToAddress(req.WalletAddress), idempotencyKey, time.Now()); err != nil {
+			code := errCodeInvalidSIWE
+			if errors.Is(err, errSIWERequired) {
+				code = errCodeSIWERequired
+			}
+			if !errors.Is(err, errSIWERequired) && !errors.Is(err, errSIWEInvalid) {
+				logger.Error("Error verifying wallet ownership proof", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(MintResponse{
+					Success: false,
+					Message: "Failed to verify wallet ownership proof",
+				})
+				return
+			}
+			logger.Warn("Rejected mint: invalid wallet ownership proof", "error", err)
+			w.WriteHeader(errorCodeStatus(code))
+			json.NewEncoder(w).Encode(MintResponse{
+				Success:   false,
+				ErrorCode: code,
+				Message:   errorCodeMessage(code),
+			})
+			return
+		}
+	}
+
+	// 同じキーの再試行かどうか（元のジョブを返すため、レート制限・上限・残高の対象外）
+	replay, err := mintQueue.store.LookupIdempotencyKey(idempotencyKey)
 	if err != nil {
-		log.Printf("Error minting SBT: %v", err)
+		logger.Error("Error looking up idempotency key", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(MintResponse{
 			Success: false,
-			Message: fmt.Sprintf("Failed to mint SBT: %v", err),
+			Message: "Failed to look up idempotency key",
 		})
 		return
 	}
 
-	// 成功レスポンス
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(MintResponse{
-		Success: true,
-		TxHash:  txHash,
-		Message: "SBT minted successfully",
-	})
-}
-
-// mintSBT - 実際のSBT Mint処理
-func mintSBT(walletAddress string) (string, error) {
-	// Polygon Amoyに接続
-	client, err := ethclient.Dial(rpcURL)
+	// レート制限（呼び出し元・送信元IP・受取ウォレットのいずれかが上限なら429）
+	// 検証に失敗したリクエストでは消費せず、再試行では受取ウォレットのトークンを消費しない
+	var allowed bool
+	var limitedKey string
+	var retryAfter time.Duration
+	if replay != nil {
+		allowed, limitedKey, retryAfter, err = rateLimiter.AllowRequest(r)
+	} else {
+		allowed, limitedKey, retryAfter, err = rateLimiter.AllowMint(r, req.WalletAddress)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to connect to network: %w", err)
+		logger.Error("Error checking rate limits", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MintResponse{
+			Success: false,
+			Message: "Failed to check rate limits",
+		})
+		return
+	}
+	if !allowed {
+		logger.Warn("Rate limited mint", "limit", limitedKey, "retryAfter", retryAfter.Round(time.Second).String())
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		w.WriteHeader(errorCodeStatus(errCodeRateLimited))
+		json.NewEncoder(w).Encode(MintResponse{
+			Success:   false,
+			ErrorCode: errCodeRateLimited,
+			Message:   errorCodeMessage(errCodeRateLimited),
+		})
+		return
 	}
-	defer client.Close()
 
-	// 秘密鍵からECDSA鍵を生成
-	privateKeyECDSA, err := crypto.HexToECDSA(privateKey)
-	if err != nil {
-		return "", fmt.Errorf("invalid private key: %w", err)
+	// クエリパラメータ ?wait=true でも同期モードを指定できる
+	if r.URL.Query().Get("wait") == "true" {
+		req.Wait = true
 	}
 
-	// 公開鍵からアドレスを取得
-	publicKey := privateKeyECDSA.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return "", fmt.Errorf("error casting public key to ECDSA")
+	// 1ウォレットあたりの上限を確認（同じキーの再試行は元の結果を返すので対象外）
+	maxPending := -1
+	if !mintPolicy.unlimited() && replay == nil {
+		held, err := minter.TokenBalance(r.Context(), req.WalletAddress)
+		if err != nil {
+			logger.Error("Error checking token balance", "error", err)
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(MintResponse{
+				Success: false,
+				Message: "Failed to check existing tokens for wallet",
+			})
+			return
+		}
+		maxPending = mintPolicy.remaining(held)
 	}
 
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
+	// 全署名者の残高が下限未満なら新しいMintを受け付けない（同じキーの再試行は元のジョブを返す）
+	if !minter.pool.Funded() && replay == nil {
+		logger.Warn("Rejected mint: no signer has sufficient funds")
+		w.WriteHeader(errorCodeStatus(errCodeInsufficientFunds))
+		json.NewEncoder(w).Encode(MintResponse{
+			Success:   false,
+			ErrorCode: errCodeInsufficientFunds,
+			Message:   errorCodeMessage(errCodeInsufficientFunds),
+		})
+		return
+	}
 
-	// Nonceの取得
-	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
+	// Mintジョブを登録（同じキーのジョブがあればそれを返す）
+	job, created, err := mintQueue.Enqueue(r.Context(), req.WalletAddress, idempotencyKey, credentialType, maxPending)
+	if errors.Is(err, errWalletLimitReached) {
+		resp := MintResponse{
+			Success:   false,
+			ErrorCode: errCodeWalletLimitReached,
+			Message:   fmt.Sprintf("Wallet already holds the maximum number of tokens (%s policy)", mintPolicy),
+		}
+		if job != nil {
+			// 処理中のジョブがある
+			resp.JobID = job.ID
+			resp.Status = job.Status
+			resp.TxHash = job.TxHash
+			resp.Message = "A mint for this wallet is already in progress"
+		} else {
+			resp.TokenID = heldTokenID(req.WalletAddress)
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	if errors.Is(err, errIdempotencyMismatch) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(MintResponse{
+			Success:   false,
+			ErrorCode: errCodeIdempotencyMismatch,
+			Message:   "Idempotency key was already used for a different wallet address",
+		})
+		return
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to get nonce: %w", err)
+		logger.Error("Error enqueuing mint job", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MintResponse{
+			Success: false,
+			Message: "Failed to enqueue mint job",
+		})
+		return
+	}
+	if created {
+		logger.Info("Mint job queued", "jobId", job.ID)
+	} else {
+		logger.Info("Replaying mint job", "jobId", job.ID, "idempotencyKey", idempotencyKey)
+		w.Header().Set("Idempotent-Replayed", "true")
 	}
 
-	// ガス価格の取得
-	gasPrice, err := client.SuggestGasPrice(context.Background())
-	if err != nil {
-		return "", fmt.Errorf("failed to suggest gas price: %w", err)
+	// 同期モードではレシートが得られるまで待つ
+	if req.Wait && !job.finished() {
+		ctx, cancel := context.WithTimeout(r.Context(), receiptTimeout)
+		defer cancel()
+		jobID := job.ID
+		if job, err = mintQueue.Wait(ctx, jobID); err != nil {
+			logger.Error("Error waiting for mint job", "jobId", jobID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(MintResponse{
+				Success: false,
+				Message: "Failed to load mint job",
+			})
+			return
+		}
 	}
 
-	// TransactOptsの作成
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKeyECDSA, chainID)
-	if err != nil {
-		return "", fmt.Errorf("failed to create transactor: %w", err)
+	w.WriteHeader(jobHTTPStatus(job))
+	json.NewEncoder(w).Encode(job.response())
+}
+
+// heldTokenID - ウォレットが保有するトークンIDを返す（分からなければ空）
+// 他の経路で発行されたトークンも含むインデックスを優先し、無ければこのサービスで確定したジョブから探す
+func heldTokenID(walletAddress string) string {
+	if records, err := tokenIndex.TokensOf(common.HexToAddress(walletAddress)); err == nil && len(records) > 0 {
+		return records[0].TokenID
+	}
+	if tokenID, err := mintQueue.store.ConfirmedTokenID(walletAddress); err == nil {
+		return tokenID
 	}
-	auth.Nonce = big.NewInt(int64(nonce))
-	auth.Value = big.NewInt(0)       // POLを送らない
-	auth.GasLimit = uint64(300000)   // ガスリミット
-	auth.GasPrice = gasPrice
+	return ""
+}
 
-	// コントラクトインスタンスの作成
-	contractAddr := common.HexToAddress(contractAddress)
-	instance, err := NewIdentitySBT(contractAddr, client)
-	if err != nil {
-		return "", fmt.Errorf("failed to instantiate contract: %w", err)
+// mintStatusHandler - Mintジョブの状態を返す
+func mintStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// CORSヘッダーを設定
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// OPTIONSリクエスト（プリフライト）の処理
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(MintResponse{
+			Success: false,
+			Message: "Method not allowed",
+		})
+		return
 	}
 
-	// safeMint関数の呼び出し
-	recipientAddress := common.HexToAddress(walletAddress)
-	tx, err := instance.SafeMint(auth, recipientAddress)
+	job, err := mintQueue.store.Get(strings.TrimSpace(r.PathValue("id")))
+	if errors.Is(err, errJobNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(MintResponse{
+			Success: false,
+			Message: "Mint job not found",
+		})
+		return
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to mint SBT: %w", err)
+		loggerFrom(r.Context()).Error("Error loading mint job", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MintResponse{
+			Success: false,
+			Message: "Failed to load mint job",
+		})
+		return
 	}
 
-	log.Printf("SBT minted successfully. TxHash: %s", tx.Hash().Hex())
-	return tx.Hash().Hex(), nil
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job.response())
+}
+
+// jobHTTPStatus - ジョブの状態に応じた /mint のHTTPステータス
+func jobHTTPStatus(job *MintJob) int {
+	switch {
+	case job.Status == jobStatusConfirmed:
+		return http.StatusOK
+	case job.Status == jobStatusFailed && job.ErrorCode != "":
+		return errorCodeStatus(job.ErrorCode)
+	case job.Reverted:
+		return http.StatusUnprocessableEntity
+	case job.Status == jobStatusFailed:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusAccepted
+	}
 }
 
 // getEnv - 環境変数を取得（デフォルト値付き）
@@ -235,8 +773,74 @@ func getEnvAsInt(key string, defaultValue int64) int64 {
 	}
 	value, err := strconv.ParseInt(valueStr, 10, 64)
 	if err != nil {
-		log.Printf("Warning: invalid value for %s, using default %d", key, defaultValue)
+		slog.Warn("Invalid environment variable, using default", "key", key, "default", defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsInterval - 定期処理の間隔（秒）を環境変数から取得する（デフォルト値付き）
+// 0以下の間隔ではタイマーを作れないため起動を中止する
+func getEnvAsInterval(key string, defaultSeconds int64) time.Duration {
+	seconds := getEnvAsInt(key, defaultSeconds)
+	if seconds <= 0 {
+		fatal("Invalid interval: must be at least 1 second", "key", key, "value", seconds)
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// getEnvAsFloat - 環境変数を小数として取得（デフォルト値付き）
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		slog.Warn("Invalid environment variable, using default", "key", key, "default", defaultValue)
 		return defaultValue
 	}
 	return value
 }
+
+// getEnvAsPOL - POL単位の環境変数をweiで取得（未設定・不正な場合はnil）
+func getEnvAsPOL(key string) *big.Int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return nil
+	}
+	value, err := parsePOL(valueStr)
+	if err != nil {
+		slog.Warn("Invalid environment variable, ignoring", "key", key)
+		return nil
+	}
+	return value
+}
+
+// getEnvAsPOLList - カンマ区切りのPOL単位の環境変数をweiで取得（不正な値は無視）
+func getEnvAsPOLList(key string) []*big.Int {
+	var values []*big.Int
+	for _, valueStr := range splitList(os.Getenv(key)) {
+		value, err := parsePOL(valueStr)
+		if err != nil {
+			slog.Warn("Invalid environment variable, ignoring", "key", key, "value", valueStr)
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// getEnvAsGwei - gwei単位の環境変数をweiで取得（未設定・不正な場合はnil）
+func getEnvAsGwei(key string) *big.Int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return nil
+	}
+	value, err := parseGwei(valueStr)
+	if err != nil {
+		slog.Warn("Invalid environment variable, ignoring", "key", key)
+		return nil
+	}
+	return value
+}
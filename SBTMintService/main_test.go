@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// TestGetEnvAsInterval - 間隔の設定値（未設定・不正な値はデフォルト）
+func TestGetEnvAsInterval(t *testing.T) {
+	const key = "MINT_TEST_INTERVAL_SECONDS"
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 30 * time.Second},
+		{value: "1", want: time.Second},
+		{value: "90", want: 90 * time.Second},
+		{value: "abc", want: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Setenv(key, tt.value)
+		if got := getEnvAsInterval(key, 30); got != tt.want {
+			t.Errorf("getEnvAsInterval(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestGetEnvAsIntervalRejectsNonPositive - 0以下の間隔では起動を中止する
+func TestGetEnvAsIntervalRejectsNonPositive(t *testing.T) {
+	const key = "MINT_TEST_INTERVAL_SECONDS"
+	// 子プロセスとして実行された場合は終了するはずの呼び出しだけを行う
+	if os.Getenv("MINT_TEST_INTERVAL_EXIT") == "1" {
+		getEnvAsInterval(key, 30)
+		return
+	}
+	for _, value := range []string{"0", "-5"} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestGetEnvAsIntervalRejectsNonPositive$")
+		cmd.Env = append(os.Environ(), "MINT_TEST_INTERVAL_EXIT=1", key+"="+value)
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			t.Errorf("getEnvAsInterval(%q) exited with %v, want exit status 1", value, err)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// metricsRegistry - /metrics で公開するメトリクス（Goランタイム・プロセスのメトリクスを含む）
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	// mintRequestsTotal - /mint のレスポンス数（outcome はジョブの状態、拒否なら rejected / error）
+	mintRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sbt_mint_requests_total",
+		Help: "Mint requests by outcome and error code.",
+	}, []string{"outcome", "error_code"})
+	// mintJobsTotal - 終了したMintジョブ数
+	mintJobsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sbt_mint_jobs_finished_total",
+		Help: "Finished mint jobs by status and error code.",
+	}, []string{"status", "error_code"})
+	// pendingJobs - 未完了のMintジョブ数（MintQueue がジョブの状態が変わるたびに更新する）
+	pendingJobs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sbt_mint_jobs_pending",
+		Help: "Unfinished mint jobs by status.",
+	}, []string{"status"})
+	// rpcDuration - Mint処理のRPC呼び出しの所要時間
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sbt_mint_rpc_duration_seconds",
+		Help:    "Latency of RPC calls made while minting.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"call"})
+	// confirmationDuration - ジョブの登録から取り込みまでの時間
+	confirmationDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sbt_mint_time_to_confirmation_seconds",
+		Help:    "Time from accepting a mint request to its transaction being mined.",
+		Buckets: []float64{2, 5, 10, 20, 30, 60, 120, 300, 600, 1800},
+	})
+	// gasUsed - 取り込まれたMintトランザクションのガス使用量
+	gasUsed = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sbt_mint_gas_used",
+		Help:    "Gas used by mined mint transactions.",
+		Buckets: prometheus.LinearBuckets(50000, 25000, 10),
+	})
+	// feePaid - 取り込まれたMintトランザクションの手数料（POL）
+	feePaid = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sbt_mint_fee_paid_pol",
+		Help:    "Fee paid by mined mint transactions in POL.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+	})
+	// httpRequestsTotal / httpDuration - エンドポイントごとのリクエスト数と所要時間
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sbt_http_requests_total",
+		Help: "HTTP requests by handler, method and status code.",
+	}, []string{"handler", "method", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sbt_http_request_duration_seconds",
+		Help:    "HTTP request latency by handler.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler"})
+)
+
+// Mint処理のRPC呼び出しの種類（rpcDuration の call）
+const (
+	rpcCallDial        = "dial"
+	rpcCallNonce       = "nonce"
+	rpcCallGasPrice    = "gas_price"
+	rpcCallEstimateGas = "estimate_gas"
+	rpcCallSend        = "send"
+	rpcCallReceipt     = "receipt"
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		mintRequestsTotal,
+		mintJobsTotal,
+		pendingJobs,
+		rpcDuration,
+		confirmationDuration,
+		gasUsed,
+		feePaid,
+		httpRequestsTotal,
+		httpDuration,
+		stateCollector{},
+	)
+	for _, status := range []string{jobStatusQueued, jobStatusSubmitted} {
+		pendingJobs.WithLabelValues(status)
+	}
+}
+
+// observeJobStatus - ジョブの状態の変化を未完了ジョブ数に反映する（from が空なら新しいジョブ）
+func observeJobStatus(from, to string) {
+	if from == to {
+		return
+	}
+	if from == jobStatusQueued || from == jobStatusSubmitted {
+		pendingJobs.WithLabelValues(from).Dec()
+	}
+	if to == jobStatusQueued || to == jobStatusSubmitted {
+		pendingJobs.WithLabelValues(to).Inc()
+	}
+}
+
+// observeRPC - start からのRPC呼び出しの所要時間を記録する（defer observeRPC(call, time.Now()) で使う）
+func observeRPC(call string, start time.Time) {
+	rpcDuration.WithLabelValues(call).Observe(time.Since(start).Seconds())
+}
+
+// instrumentMint - /mint のレスポンスのステータスとエラーコードを記録する
+// outcome は受け付けたジョブの状態、4xxなら rejected、5xxなら error
+func instrumentMint(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK, body: new(bytes.Buffer)}
+		next(rec, r)
+		if r.Method == http.MethodOptions {
+			return
+		}
+
+		var resp MintResponse
+		json.Unmarshal(rec.body.Bytes(), &resp)
+		outcome := resp.Status
+		switch {
+		case rec.status >= http.StatusInternalServerError:
+			outcome = "error"
+		case rec.status >= http.StatusBadRequest:
+			outcome = "rejected"
+		}
+		mintRequestsTotal.WithLabelValues(outcome, resp.ErrorCode).Inc()
+	}
+}
+
+// observeBatchItems - 一括Mintの宛先ごとの結果を /mint のレスポンスと同じく記録する（拒否した宛先は rejected）
+func observeBatchItems(items []BatchItemResult) {
+	for _, item := range items {
+		outcome := item.Status
+		if item.JobID == "" {
+			outcome = "rejected"
+		}
+		mintRequestsTotal.WithLabelValues(outcome, item.ErrorCode).Inc()
+	}
+}
+
+// observeFinishedJob - 終了したジョブの結果・取り込みまでの時間・ガス・手数料を記録する
+func observeFinishedJob(job *MintJob) {
+	mintJobsTotal.WithLabelValues(job.Status, job.ErrorCode).Inc()
+	if job.BlockNumber == 0 {
+		return
+	}
+	if job.Status == jobStatusConfirmed {
+		confirmationDuration.Observe(job.UpdatedAt.Sub(job.CreatedAt).Seconds())
+	}
+	gasUsed.Observe(float64(job.GasUsed))
+	if fee, ok := new(big.Int).SetString(job.FeePaid, 10); ok {
+		feePaid.Observe(weiToPOL(fee))
+	}
+}
+
+// instrumentHandler - エンドポイントのリクエスト数と所要時間を記録し、リクエストのスパンを作る
+// 呼び出し元の traceparent ヘッダーがあればそのトレースに続け、ログにも traceId を付ける
+func instrumentHandler(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", name),
+				attribute.String("request.id", requestIDFrom(ctx))))
+		defer span.End()
+		if sc := span.SpanContext(); sc.IsValid() {
+			ctx = withLogger(ctx, loggerFrom(ctx).With("traceId", sc.TraceID().String()))
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(ctx))
+		httpRequestsTotal.WithLabelValues(name, r.Method, strconv.Itoa(rec.status)).Inc()
+		httpDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	}
+}
+
+// statusRecorder - レスポンスのステータスコード（body が設定されていれば本文も）を記録する
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   *bytes.Buffer
+}
+
+// Write - 本文を記録してから書き込む
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.body != nil {
+		r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// WriteHeader - ステータスコードを記録してから書き込む
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// metricsHandler - Prometheus形式でメトリクスを返す
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelError)})
+}
+
+// stateCollector - スクレイプ時に署名者の状態を取得するコレクター
+type stateCollector struct{}
+
+var (
+	signerBalanceDesc = prometheus.NewDesc("sbt_signer_balance_pol",
+		"Last checked signer balance in POL.", []string{"signer"}, nil)
+	signerRemainingDesc = prometheus.NewDesc("sbt_signer_remaining_mints",
+		"Estimated number of mints the signer balance can pay for above the minimum.", []string{"signer"}, nil)
+	signerActiveJobsDesc = prometheus.NewDesc("sbt_signer_active_jobs",
+		"Mint jobs assigned to the signer.", []string{"signer"}, nil)
+	signerAvailableDesc = prometheus.NewDesc("sbt_signer_available",
+		"Whether new mints can be assigned to the signer (1) or not (0).", []string{"signer"}, nil)
+)
+
+// Describe - コレクターのメトリクス
+func (stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- signerBalanceDesc
+	ch <- signerRemainingDesc
+	ch <- signerActiveJobsDesc
+	ch <- signerAvailableDesc
+}
+
+// Collect - 署名者のプールから現在の値を取得する（/metrics はプールの作成後に公開する）
+func (stateCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range minter.pool.Status() {
+		if balance, ok := new(big.Int).SetString(status.Balance, 10); ok {
+			ch <- prometheus.MustNewConstMetric(signerBalanceDesc, prometheus.GaugeValue, weiToPOL(balance), status.Address)
+		}
+		if status.RemainingMints != nil {
+			ch <- prometheus.MustNewConstMetric(signerRemainingDesc, prometheus.GaugeValue, float64(*status.RemainingMints), status.Address)
+		}
+		ch <- prometheus.MustNewConstMetric(signerActiveJobsDesc, prometheus.GaugeValue, float64(status.ActiveJobs), status.Address)
+		available := 0.0
+		if status.Available {
+			available = 1
+		}
+		ch <- prometheus.MustNewConstMetric(signerAvailableDesc, prometheus.GaugeValue, available, status.Address)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Mintトランザクションの状態
+const (
+	mintStatusSubmitted = "submitted"
+	mintStatusConfirmed = "confirmed"
+	mintStatusReverted  = "reverted"
+)
+
+// errMintReverted - トランザクションがチェーン上でrevertされた
+var errMintReverted = errors.New("mint transaction reverted")
+
+// maxNonceAttempts - nonce too low の場合に再同期してやり直す回数
+const maxNonceAttempts = 3
+
+// transferEventID - Transfer(address,address,uint256) イベントのトピック
+var transferEventID = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// MintResult - Mint処理の結果
+type MintResult struct {
+	TxHash      string
+	Status      string
+	TokenID     *big.Int
+	BlockNumber uint64
+	GasUsed     uint64
+	// EffectiveGasPrice - 実際に支払ったガス単価（wei）
+	EffectiveGasPrice *big.Int
+	// FeePaid - 支払った手数料の合計（wei）
+	FeePaid *big.Int
+}
+
+// MintSBT - 実際のSBT Mint処理
+// signer で署名したトランザクションを onSigned に渡して保存してから送信する
+func (m *Minter) MintSBT(ctx context.Context, signer *poolSigner, walletAddress string, onSigned func(*types.Transaction) error) (*types.Transaction, error) {
+	client, instance, err := m.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 手数料の取得（EIP-1559対応チェーンではbase fee + チップ）
+	fees, err := m.suggestFees(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	recipientAddress := common.HexToAddress(walletAddress)
+
+	// ガスリミットの見積もり（revertする場合は送信前にエラーになる）
+	gasLimit, err := m.estimateMintGas(ctx, client, signer.Address(), recipientAddress, fees)
+	if err != nil {
+		return nil, err
+	}
+	m.lastMintGas.Store(gasLimit)
+
+	// 他のMintが同じNonceを使っていた場合はチェーンと再同期してやり直す
+	logger := loggerFrom(ctx)
+	nonces := signer.nonces
+	for attempt := 1; ; attempt++ {
+		// Nonceの取得
+		nonce, err := nonces.Acquire(ctx, client)
+		if err := m.observe(err); err != nil {
+			return nil, err
+		}
+
+		// TransactOptsの作成
+		auth := transactOpts(ctx, signer, m.chainID)
+		auth.Nonce = new(big.Int).SetUint64(nonce)
+		auth.Value = big.NewInt(0) // POLを送らない
+		auth.GasLimit = gasLimit
+		fees.apply(auth)
+		auth.NoSend = true // 保存してから送信する
+
+		// safeMint関数の呼び出し（署名のみ）
+		tx, err := instance.SafeMint(auth, recipientAddress)
+		if err != nil {
+			nonces.Release(nonce)
+			return nil, fmt.Errorf("failed to mint SBT: %w", err)
+		}
+
+		if err := onSigned(tx); err != nil {
+			nonces.Release(nonce)
+			return nil, fmt.Errorf("failed to persist signed transaction: %w", err)
+		}
+
+		sendCtx, span := startRPC(ctx, rpcCallSend)
+		span.SetAttributes(attribute.String("tx.hash", tx.Hash().Hex()), attribute.Int64("tx.nonce", int64(nonce)))
+		err = m.observe(client.SendTransaction(sendCtx, tx))
+		span.end(err)
+		switch {
+		case err == nil || isAlreadyKnown(err):
+			nonces.Done(nonce)
+		case isNonceTooLow(err):
+			nonces.Done(nonce)
+			nonces.Reset()
+			if attempt < maxNonceAttempts {
+				logger.Warn("Nonce already used, resynchronizing", "nonce", nonce, "attempt", attempt)
+				continue
+			}
+			return nil, fmt.Errorf("failed to send transaction: %w", err)
+		case errors.Is(err, context.DeadlineExceeded):
+			// 呼び出し元の期限切れは失敗として返す
+			// ノードが受け付けたかは分からないため、Nonceはチェーンから取り直す
+			nonces.Done(nonce)
+			nonces.Reset()
+			return nil, fmt.Errorf("transaction send timed out: %w", err)
+		case isConnectionError(err):
+			// ノードが受け付けた後に接続が切れた可能性があるため、Nonceを使用済みにして送信済みとして扱う
+			// 取り込まれたかは track のレシート待ちと recoverDropped の再送信で判断する
+			nonces.Done(nonce)
+			logger.Warn("Send result unknown, tracking transaction as submitted",
+				"txHash", tx.Hash().Hex(), "signer", signer.Address().Hex(), "nonce", nonce, "error", err)
+			return tx, nil
+		default:
+			nonces.Release(nonce)
+			return nil, fmt.Errorf("failed to send transaction: %w", err)
+		}
+
+		logger.Info("SBT mint transaction sent",
+			"txHash", tx.Hash().Hex(), "signer", signer.Address().Hex(), "nonce", nonce, "gasLimit", gasLimit, "fees", fees.String())
+		return tx, nil
+	}
+}
+
+// WaitForMint - ジョブのトランザクションのいずれかが取り込まれるまで待ち、Transferイベントから発行されたトークンIDを取得する
+// hashes は待機中に置き換えられたトランザクションも含めた最新の候補を返す
+// 待機全体を1つのスパンにする（個々のレシート取得はメトリクスのみ記録する）
+func (m *Minter) WaitForMint(ctx context.Context, hashes func() []common.Hash) (result *MintResult, err error) {
+	ctx, span := tracer.Start(ctx, "mint.wait_for_receipt")
+	polls := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("mint.receipt_polls", polls))
+		if result != nil {
+			span.SetAttributes(attribute.String("tx.hash", result.TxHash), attribute.String("mint.status", result.Status))
+		}
+		endSpan(span, err)
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for ; ; polls++ {
+		if client, instance, err := m.connect(ctx); err == nil {
+			for _, hash := range hashes() {
+				start := time.Now()
+				receipt, err := client.TransactionReceipt(ctx, hash)
+				observeRPC(rpcCallReceipt, start)
+				if errors.Is(err, ethereum.NotFound) {
+					continue
+				}
+				if m.observe(err) != nil {
+					break
+				}
+				return m.receiptResult(ctx, client, instance, hash, receipt)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to wait for receipt: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// receiptResult - 取り込まれたトランザクションのレシートからMintResultを作る
+func (m *Minter) receiptResult(ctx context.Context, client chainClient, instance *IdentitySBT, hash common.Hash, receipt *types.Receipt) (*MintResult, error) {
+	result := &MintResult{TxHash: hash.Hex(), Status: mintStatusSubmitted}
+	if err := applyReceipt(result, receipt, instance); err != nil {
+		if errors.Is(err, errMintReverted) {
+			if tx, _, txErr := client.TransactionByHash(ctx, hash); txErr == nil {
+				if revertErr := m.replayRevert(ctx, client, tx, receipt.BlockNumber); revertErr != nil {
+					err = fmt.Errorf("%w: %w", errMintReverted, revertErr)
+				}
+			}
+		}
+		return result, err
+	}
+	loggerFrom(ctx).Info("SBT minted successfully", "txHash", result.TxHash, "tokenId", result.TokenID.String(),
+		"blockNumber", result.BlockNumber, "gasUsed", result.GasUsed)
+	return result, nil
+}
+
+// rebroadcast - 保存済みの署名済みトランザクションを再送信する（既に取り込み済みならエラーは無視）
+func (m *Minter) rebroadcast(ctx context.Context, rawTx string) {
+	logger := loggerFrom(ctx)
+	tx, err := decodeRawTx(rawTx)
+	if err != nil {
+		logger.Error("Cannot rebroadcast", "error", err)
+		return
+	}
+	client, _, err := m.connect(ctx)
+	if err != nil {
+		logger.Warn("Rebroadcast failed", "txHash", tx.Hash().Hex(), "error", err)
+		return
+	}
+	if err := m.observe(client.SendTransaction(ctx, tx)); err != nil {
+		logger.Info("Rebroadcast not accepted", "txHash", tx.Hash().Hex(), "error", err)
+	}
+}
+
+// replayRevert - revertしたトランザクションを取り込まれたブロックの直前の状態で再実行し、revert理由を解析する
+// 同じブロック内の先行トランザクションの影響は再現できないため、解析できない場合はnilを返す
+func (m *Minter) replayRevert(ctx context.Context, client chainClient, tx *types.Transaction, blockNumber *big.Int) *RevertError {
+	if blockNumber == nil || blockNumber.Sign() == 0 {
+		return nil
+	}
+	from, err := txSender(tx)
+	if err != nil {
+		return nil
+	}
+	_, err = client.CallContract(ctx, ethereum.CallMsg{
+		From:  from,
+		To:    tx.To(),
+		Gas:   tx.Gas(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}, new(big.Int).Sub(blockNumber, big.NewInt(1)))
+	return decodeRevert(err)
+}
+
+// applyReceipt - レシートの内容をMintResultに反映する
+func applyReceipt(result *MintResult, receipt *types.Receipt, instance *IdentitySBT) error {
+	result.BlockNumber = receipt.BlockNumber.Uint64()
+	result.GasUsed = receipt.GasUsed
+	if receipt.EffectiveGasPrice != nil {
+		result.EffectiveGasPrice = receipt.EffectiveGasPrice
+		result.FeePaid = new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		result.Status = mintStatusReverted
+		return errMintReverted
+	}
+	result.Status = mintStatusConfirmed
+
+	// Transferイベント（from = 0x0）から発行されたトークンIDを取得
+	for _, l := range receipt.Logs {
+		if len(l.Topics) == 0 || l.Topics[0] != transferEventID {
+			continue
+		}
+		event, err := instance.ParseTransfer(*l)
+		if err != nil {
+			continue
+		}
+		if event.From == (common.Address{}) {
+			result.TokenID = event.TokenId
+			break
+		}
+	}
+	if result.TokenID == nil {
+		return fmt.Errorf("no Transfer event found in receipt %s", result.TxHash)
+	}
+	return nil
+}
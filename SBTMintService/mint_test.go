@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testMintBytecode - safeMint(to) の代わりに連番のトークンIDで Transfer(0x0, to, id) を発行するだけのコントラクト
+// （リポジトリにはIdentitySBTのバイトコードが無いため、レシートの解析に必要な動作だけを再現する）
+func testMintBytecode() []byte {
+	runtime := []byte{
+		0x60, 0x00, 0x54, // SLOAD(0)
+		0x60, 0x01, 0x01, // +1 → tokenId
+		0x80, 0x60, 0x00, 0x55, // SSTORE(0, tokenId)
+		0x60, 0x04, 0x35, // CALLDATALOAD(4) → to
+		0x60, 0x00, // from = 0x0
+		0x7f, // PUSH32 Transfer
+	}
+	runtime = append(runtime, transferEventID.Bytes()...)
+	runtime = append(runtime,
+		0x60, 0x00, 0x60, 0x00, // LOG4(0, 0, Transfer, from, to, tokenId)
+		0xa4,
+		0x00, // STOP
+	)
+	// runtime をメモリにコピーして返すデプロイ用のコード
+	initcode := []byte{0x60, byte(len(runtime)), 0x80, 0x60, 0x0b, 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}
+	return append(initcode, runtime...)
+}
+
+// newSimulatedMinter - テスト用コントラクトをデプロイしたシミュレートのチェーンと、1つの署名者を持つMinter
+func newSimulatedMinter(t *testing.T) (*Minter, *simulated.Backend) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	sim := simulated.NewBackend(types.GenesisAlloc{
+		owner: {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))},
+	})
+	t.Cleanup(func() { sim.Close() })
+	client := sim.Client()
+
+	chainID, err := client.ChainID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract, _, _, err := bind.DeployContract(auth, *identitySBTABI, testMintBytecode(), client)
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	sim.Commit()
+
+	pool, err := newSignerPool([]Signer{newKeySigner(key)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Minter{
+		chainID:  chainID,
+		contract: contract,
+		pool:     pool,
+		gas:      GasConfig{Multiplier: 1.2, MaxGas: 500000},
+		dial: func(context.Context) (chainClient, error) {
+			return client, nil
+		},
+	}
+	return m, sim
+}
+
+// TestMintSBTSimulated - シミュレートしたチェーンで署名・保存・送信し、レシートからトークンIDを取得する
+func TestMintSBTSimulated(t *testing.T) {
+	m, sim := newSimulatedMinter(t)
+	pool := m.pool
+	ctx := context.Background()
+
+	recipients := []common.Address{
+		common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		common.HexToAddress("0x2222222222222222222222222222222222222222"),
+	}
+	for i, recipient := range recipients {
+		signer, err := pool.Acquire("job")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var signed *types.Transaction
+		tx, err := m.MintSBT(ctx, signer, recipient.Hex(), func(tx *types.Transaction) error {
+			signed = tx
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("mint %d: MintSBT: %v", i, err)
+		}
+		pool.Release("job")
+		if signed == nil || signed.Hash() != tx.Hash() {
+			t.Fatalf("mint %d: onSigned was not called with the sent transaction", i)
+		}
+		// デプロイで0を使っているので1から
+		if tx.Nonce() != uint64(i+1) {
+			t.Errorf("mint %d: nonce = %d, want %d", i, tx.Nonce(), i+1)
+		}
+		sim.Commit()
+
+		result, err := m.WaitForMint(ctx, func() []common.Hash { return []common.Hash{tx.Hash()} })
+		if err != nil {
+			t.Fatalf("mint %d: WaitForMint: %v", i, err)
+		}
+		if result.Status != mintStatusConfirmed || result.TxHash != tx.Hash().Hex() {
+			t.Errorf("mint %d: result = %s %s, want confirmed %s", i, result.Status, result.TxHash, tx.Hash().Hex())
+		}
+		if result.TokenID == nil || result.TokenID.Int64() != int64(i+1) {
+			t.Errorf("mint %d: tokenId = %v, want %d", i, result.TokenID, i+1)
+		}
+		if result.GasUsed == 0 || result.FeePaid == nil || result.FeePaid.Sign() <= 0 {
+			t.Errorf("mint %d: gasUsed = %d, feePaid = %v, want both recorded", i, result.GasUsed, result.FeePaid)
+		}
+	}
+}
+
+// sendFailingClient - SendTransaction だけが err を返すクライアント
+type sendFailingClient struct {
+	chainClient
+	err error
+}
+
+func (c sendFailingClient) SendTransaction(context.Context, *types.Transaction) error {
+	return c.err
+}
+
+// TestMintSBTSendFailure - 接続エラーは送信済みとして扱い、呼び出し元の期限切れは失敗としてNonceを取り直す
+func TestMintSBTSendFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantSubmitted bool
+	}{
+		{
+			name:          "connection refused",
+			err:           &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			wantSubmitted: true,
+		},
+		{
+			name: "caller deadline",
+			err:  &url.Error{Op: "Post", URL: "http://rpc.example", Err: context.DeadlineExceeded},
+		},
+	}
+	for _, tt := range tests {
+		m, _ := newSimulatedMinter(t)
+		dial := m.dial
+		m.dial = func(ctx context.Context) (chainClient, error) {
+			client, err := dial(ctx)
+			return sendFailingClient{chainClient: client, err: tt.err}, err
+		}
+		signer, err := m.pool.Acquire("job")
+		if err != nil {
+			t.Fatal(err)
+		}
+		recipient := "0x1111111111111111111111111111111111111111"
+		tx, err := m.MintSBT(context.Background(), signer, recipient, func(*types.Transaction) error { return nil })
+		if tt.wantSubmitted {
+			if err != nil || tx == nil {
+				t.Errorf("%s: MintSBT = %v, %v, want the transaction tracked as submitted", tt.name, tx, err)
+			}
+			continue
+		}
+		if tx != nil || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: MintSBT = %v, %v, want a deadline error", tt.name, tx, err)
+		}
+
+		// 送信されなかったNonceはチェーンから取り直して再利用する
+		m.dial = dial
+		m.client = nil
+		tx, err = m.MintSBT(context.Background(), signer, recipient, func(*types.Transaction) error { return nil })
+		if err != nil || tx.Nonce() != 1 {
+			t.Errorf("%s: next MintSBT = %v, %v, want nonce 1", tt.name, tx, err)
+		}
+	}
+}
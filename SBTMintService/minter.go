@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// unhealthyAfter - 連続してこの回数RPCに失敗したら異常とみなす
+const unhealthyAfter = 3
+
+// chainClient - Minterが使うRPCクライアント
+// 本番では *ethclient.Client、テストでは simulated.Client を渡せる
+type chainClient interface {
+	bind.ContractBackend
+	ethereum.BlockNumberReader
+	ethereum.TransactionReader
+	ethereum.ChainIDReader
+	ethereum.ChainStateReader
+}
+
+// MinterConfig - Minterの設定
+type MinterConfig struct {
+	RPCURL          string
+	ContractAddress common.Address
+	ChainID         *big.Int
+	Signers         []Signer
+	// MinSignerBalance - これ未満の残高（wei）の署名者には新しいMintを割り当てない（nilなら制限しない）
+	MinSignerBalance *big.Int
+	Fees             FeeConfig
+	Gas              GasConfig
+}
+
+// MinterHealth - RPC接続の状態
+type MinterHealth struct {
+	Healthy             bool       `json:"healthy"`
+	Connected           bool       `json:"connected"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastSuccess         *time.Time `json:"lastSuccess,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// Minter - RPC接続・署名者のプール・コントラクトを保持する長寿命のMint処理コンポーネント
+//
+// main で一度だけ作成し、リクエストごとの接続や鍵の解析を行わない。
+// 接続エラーが起きたクライアントは破棄され、次の呼び出しで再接続する。
+type Minter struct {
+	chainID  *big.Int
+	contract common.Address
+	pool     *SignerPool
+	fees     FeeConfig
+	gas      GasConfig
+	dial     func(ctx context.Context) (chainClient, error)
+
+	// lastMintGas - 直近のMintで見積もったガスリミット（残高で送信できるMint回数の見積もりに使う）
+	lastMintGas atomic.Uint64
+
+	mu       sync.Mutex
+	client   chainClient
+	instance *IdentitySBT
+	health   MinterHealth
+}
+
+// newMinter - Minterを作成する（接続は最初の呼び出し時に行う）
+func newMinter(cfg MinterConfig) (*Minter, error) {
+	pool, err := newSignerPool(cfg.Signers, cfg.MinSignerBalance)
+	if err != nil {
+		return nil, err
+	}
+	return &Minter{
+		chainID:  cfg.ChainID,
+		contract: cfg.ContractAddress,
+		pool:     pool,
+		fees:     cfg.Fees,
+		gas:      cfg.Gas,
+		dial: func(ctx context.Context) (chainClient, error) {
+			return ethclient.DialContext(ctx, cfg.RPCURL)
+		},
+	}, nil
+}
+
+// signTx - 署名者でトランザクションに署名する
+func (m *Minter) signTx(ctx context.Context, signer Signer, txdata types.TxData) (*types.Transaction, error) {
+	return signer.SignTx(ctx, types.NewTx(txdata), m.chainID)
+}
+
+// Close - 接続を閉じる
+func (m *Minter) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.disconnect()
+}
+
+// connect - 接続済みのクライアントとコントラクトを返す（未接続・切断後は再接続する）
+func (m *Minter) connect(ctx context.Context) (chainClient, *IdentitySBT, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.client != nil {
+		return m.client, m.instance, nil
+	}
+
+	dialCtx, span := startRPC(ctx, rpcCallDial)
+	client, err := m.dial(dialCtx)
+	span.end(err)
+	if err != nil {
+		m.recordFailure(err)
+		return nil, nil, fmt.Errorf("failed to connect to network: %w", err)
+	}
+	instance, err := NewIdentitySBT(m.contract, client)
+	if err != nil {
+		closeClient(client)
+		return nil, nil, fmt.Errorf("failed to instantiate contract: %w", err)
+	}
+	m.client = client
+	m.instance = instance
+	return client, instance, nil
+}
+
+// observe - RPC呼び出しの結果を記録し、接続エラーなら次回再接続させる
+func (m *Minter) observe(err error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		now := time.Now().UTC()
+		m.health.LastSuccess = &now
+		m.health.ConsecutiveFailures = 0
+		return nil
+	}
+	if isConnectionError(err) {
+		m.recordFailure(err)
+		m.disconnect()
+	}
+	return err
+}
+
+// Health - 現在の接続状態を返す
+func (m *Minter) Health() MinterHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.health
+	h.Connected = m.client != nil
+	h.Healthy = h.ConsecutiveFailures < unhealthyAfter
+	return h
+}
+
+// watchHealth - 定期的にRPCへ問い合わせて接続状態を更新する
+func (m *Minter) watchHealth(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := m.ping(ctx); err != nil {
+			slog.Warn("RPC health check failed", "error", err)
+		}
+		cancel()
+	}
+}
+
+// ping - 最新ブロック番号を取得して接続を確認する
+func (m *Minter) ping(ctx context.Context) error {
+	client, _, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = client.BlockNumber(ctx)
+	return m.observe(err)
+}
+
+// recordFailure - 失敗を記録する（m.mu を保持して呼ぶ）
+func (m *Minter) recordFailure(err error) {
+	now := time.Now().UTC()
+	m.health.ConsecutiveFailures++
+	m.health.LastError = err.Error()
+	m.health.LastErrorAt = &now
+}
+
+// disconnect - クライアントを破棄する（m.mu を保持して呼ぶ）
+func (m *Minter) disconnect() {
+	if m.client != nil {
+		closeClient(m.client)
+	}
+	m.client = nil
+	m.instance = nil
+}
+
+// closeClient - Closeを持つクライアントなら閉じる
+func closeClient(client chainClient) {
+	if c, ok := client.(interface{ Close() }); ok {
+		c.Close()
+	}
+}
+
+// isConnectionError - RPCノードに到達できなかったエラーかどうか
+// ノードがJSON-RPCエラーを返した場合（revert等）は接続は正常とみなす
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return false
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == 429
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
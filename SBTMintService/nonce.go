@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ノードが返すトランザクションプールのエラー（RPC越しでは文字列でしか判別できない）
+const (
+	txErrNonceTooLow  = "nonce too low"
+	txErrAlreadyKnown = "already known"
+	txErrKnownTx      = "known transaction"
+)
+
+// isNonceTooLow - Nonceが既に使われているエラーか
+func isNonceTooLow(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), txErrNonceTooLow)
+}
+
+// isAlreadyKnown - 同じトランザクションが既にプールにあるエラーか
+func isAlreadyKnown(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, txErrAlreadyKnown) || strings.Contains(msg, txErrKnownTx)
+}
+
+// nonceSource - チェーン上のpending Nonceを取得できるもの（*ethclient.Client）
+type nonceSource interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// NonceManager - 署名アドレスのNonceをプロセス内で一元的に払い出す
+//
+// 同時に処理されるMintが同じNonceを取り合わないよう払い出しを直列化する。
+// 送信されなかったNonceは返却され、次の払い出しで優先的に再利用される。
+// エラー後は Reset により次回の払い出し時にチェーンと再同期する。
+type NonceManager struct {
+	address common.Address
+
+	mu       sync.Mutex
+	synced   bool
+	next     uint64
+	inflight map[uint64]bool      // 払い出し済みで送信結果が確定していないNonce
+	released map[uint64]time.Time // 送信されずに返却されたNonce（ギャップ）と返却時刻
+}
+
+// newNonceManager - アドレスごとのNonceマネージャーを作成
+func newNonceManager(address common.Address) *NonceManager {
+	return &NonceManager{
+		address:  address,
+		inflight: make(map[uint64]bool),
+		released: make(map[uint64]time.Time),
+	}
+}
+
+// Acquire - 次に使うNonceを払い出す
+// 未同期の場合はチェーンのpending Nonceと照合してから払い出す
+func (m *NonceManager) Acquire(ctx context.Context, src nonceSource) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.synced {
+		if err := m.sync(ctx, src); err != nil {
+			return 0, err
+		}
+	}
+
+	// 返却されたNonceがあれば小さい順に再利用してギャップを埋める
+	if gaps := m.sortedReleased(); len(gaps) > 0 {
+		nonce := gaps[0]
+		delete(m.released, nonce)
+		m.inflight[nonce] = true
+		return nonce, nil
+	}
+
+	nonce := m.next
+	m.next++
+	m.inflight[nonce] = true
+	return nonce, nil
+}
+
+// Done - 送信に成功した（またはノードが既に受け取っている）Nonceを確定する
+func (m *NonceManager) Done(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.inflight, nonce)
+}
+
+// Release - 送信されなかったNonceを返却する
+func (m *NonceManager) Release(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.inflight, nonce)
+	if nonce+1 == m.next {
+		// 最後に払い出したNonceならそのまま巻き戻す
+		m.next--
+		return
+	}
+	if nonce < m.next {
+		m.released[nonce] = time.Now()
+	}
+}
+
+// Reset - 次回の払い出し時にチェーンと再同期させる（nonce too low などの後に呼ぶ）
+func (m *NonceManager) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.synced = false
+}
+
+// TakeStaleGaps - 返却されてから olderThan 以上再利用されていないNonceを取り出す
+// 取り出したNonceは払い出し済みとして扱われ、呼び出し側が Done / Release する
+func (m *NonceManager) TakeStaleGaps(olderThan time.Duration) []uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stale []uint64
+	for _, nonce := range m.sortedReleased() {
+		if time.Since(m.released[nonce]) >= olderThan {
+			delete(m.released, nonce)
+			m.inflight[nonce] = true
+			stale = append(stale, nonce)
+		}
+	}
+	return stale
+}
+
+// sync - チェーンのpending Nonceと照合する（m.mu を保持して呼ぶ）
+func (m *NonceManager) sync(ctx context.Context, src nonceSource) error {
+	ctx, span := startRPC(ctx, rpcCallNonce)
+	pending, err := src.PendingNonceAt(ctx, m.address)
+	span.end(err)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %w", err)
+	}
+
+	// 送信中のNonceより小さい値には戻さない
+	next := pending
+	for nonce := range m.inflight {
+		if nonce+1 > next {
+			next = nonce + 1
+		}
+	}
+	// チェーンで既に使われたギャップは捨てる
+	for nonce := range m.released {
+		if nonce < pending || nonce >= next {
+			delete(m.released, nonce)
+		}
+	}
+	if m.synced && next != m.next {
+		slog.Info("Nonce resynchronized", "signer", m.address.Hex(), "from", m.next, "to", next)
+	}
+	m.next = next
+	m.synced = true
+	return nil
+}
+
+// sortedReleased - 返却済みNonceを昇順で返す（m.mu を保持して呼ぶ）
+func (m *NonceManager) sortedReleased() []uint64 {
+	gaps := make([]uint64, 0, len(m.released))
+	for nonce := range m.released {
+		gaps = append(gaps, nonce)
+	}
+	sort.Slice(gaps, func(i, k int) bool { return gaps[i] < gaps[k] })
+	return gaps
+}
+
+// watchNonces - 定期的にNonceの整合性を確認し、取り込まれないトランザクションを置き換える
+func (q *MintQueue) watchNonces(interval, gapTimeout time.Duration, stuck StuckConfig) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := q.reconcileNonces(context.Background(), gapTimeout, stuck); err != nil {
+			slog.Warn("Nonce reconciliation failed", "error", err)
+		}
+	}
+}
+
+// reconcileNonces - ノードから消えた送信済みトランザクションを再送信し、
+// 時間が経っても取り込まれないものは手数料を引き上げて置き換え、
+// 埋まらないまま残ったNonceのギャップを自分宛ての空トランザクションで埋める
+func (q *MintQueue) reconcileNonces(ctx context.Context, gapTimeout time.Duration, stuck StuckConfig) error {
+	client, _, err := q.minter.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := q.store.Unfinished()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.Status != jobStatusSubmitted {
+			continue
+		}
+		if q.recoverDropped(ctx, client, job) {
+			q.replaceStuck(ctx, client, job, stuck)
+		}
+	}
+
+	// 排出中の署名者も含めて、署名者ごとのギャップを埋める
+	for _, signer := range q.minter.pool.signers {
+		nonces := signer.nonces
+		for _, nonce := range nonces.TakeStaleGaps(gapTimeout) {
+			if err := q.minter.fillNonceGap(ctx, client, signer, nonce); err != nil {
+				slog.Error("Failed to fill nonce gap", "signer", signer.Address().Hex(), "nonce", nonce, "error", err)
+				nonces.Release(nonce)
+				continue
+			}
+			nonces.Done(nonce)
+		}
+	}
+	return nil
+}
+
+// recoverDropped - ノードが送信済みトランザクションを知らない場合に再送信する
+// トランザクションがまだ取り込まれずにmempoolに残っている場合はtrueを返す
+func (q *MintQueue) recoverDropped(ctx context.Context, client chainClient, job *MintJob) bool {
+	hash := common.HexToHash(job.TxHash)
+	_, isPending, err := client.TransactionByHash(ctx, hash)
+	if !errors.Is(err, ethereum.NotFound) {
+		q.minter.observe(err)
+		return err == nil && isPending
+	}
+	// 取り込まれた直後の可能性もあるので、置き換え前のものも含めてレシートを確認する
+	for _, hash := range job.txHashes() {
+		if _, err := client.TransactionReceipt(ctx, hash); err == nil {
+			return false
+		}
+	}
+
+	logger := jobLogger(job)
+	tx, err := decodeRawTx(job.RawTx)
+	if err != nil {
+		logger.Error("Cannot rebroadcast", "error", err)
+		return false
+	}
+	err = q.minter.observe(client.SendTransaction(ctx, tx))
+	switch {
+	case err == nil || isAlreadyKnown(err):
+		logger.Info("Rebroadcast dropped transaction", "txHash", job.TxHash, "nonce", tx.Nonce())
+	case isNonceTooLow(err):
+		// 同じNonceが別のトランザクションで使われたため、このトランザクションは取り込まれない
+		logger.Warn("Transaction was dropped and its nonce reused", "txHash", job.TxHash, "nonce", tx.Nonce())
+		q.abandon(job.ID, fmt.Sprintf("transaction dropped: nonce %d was used by another transaction", tx.Nonce()))
+	default:
+		logger.Warn("Failed to rebroadcast", "txHash", job.TxHash, "error", err)
+	}
+	return false
+}
+
+// fillNonceGap - 署名者の指定したNonceで自分宛ての0 POL送金を送り、後続のトランザクションを進める
+func (m *Minter) fillNonceGap(ctx context.Context, client chainClient, signer *poolSigner, nonce uint64) error {
+	fees, err := m.suggestFees(ctx, client)
+	if err != nil {
+		return err
+	}
+	from := signer.Address()
+
+	var txdata types.TxData = &types.LegacyTx{
+		Nonce:    nonce,
+		To:       &from,
+		Value:    big.NewInt(0),
+		Gas:      21000,
+		GasPrice: fees.GasPrice,
+	}
+	if fees.dynamic() {
+		txdata = &types.DynamicFeeTx{
+			ChainID:   m.chainID,
+			Nonce:     nonce,
+			To:        &from,
+			Value:     big.NewInt(0),
+			Gas:       21000,
+			GasTipCap: fees.GasTipCap,
+			GasFeeCap: fees.GasFeeCap,
+		}
+	}
+	tx, err := m.signTx(ctx, signer, txdata)
+	if err != nil {
+		return fmt.Errorf("failed to sign gap filler: %w", err)
+	}
+	err = m.observe(client.SendTransaction(ctx, tx))
+	if isNonceTooLow(err) {
+		// 既に別のトランザクションで埋まっている
+		return nil
+	}
+	if err != nil && !isAlreadyKnown(err) {
+		return err
+	}
+	slog.Info("Filled nonce gap", "signer", from.Hex(), "nonce", nonce, "txHash", tx.Hash().Hex())
+	return nil
+}
+
+// decodeRawTx - 保存済みの署名済みトランザクションを復元する
+func decodeRawTx(rawTx string) (*types.Transaction, error) {
+	raw, err := hexutil.Decode(rawTx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored transaction: %w", err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("invalid stored transaction: %w", err)
+	}
+	return tx, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// testNonceSource - チェーンのpending Nonceを返す
+type testNonceSource struct {
+	pending uint64
+	err     error
+}
+
+func (s *testNonceSource) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return s.pending, s.err
+}
+
+// TestNonceManager - 払い出し・返却・確定・再同期の組み合わせ
+func TestNonceManager(t *testing.T) {
+	type op struct {
+		kind    string // acquire / release / done / reset / pending
+		nonce   uint64 // acquire の期待値、release / done / pending の引数
+		wantErr bool
+	}
+	tests := []struct {
+		name string
+		ops  []op
+	}{
+		{
+			name: "sequential from chain",
+			ops: []op{
+				{kind: "pending", nonce: 5},
+				{kind: "acquire", nonce: 5},
+				{kind: "acquire", nonce: 6},
+				{kind: "done", nonce: 5},
+				{kind: "acquire", nonce: 7},
+			},
+		},
+		{
+			name: "release of last nonce rewinds",
+			ops: []op{
+				{kind: "acquire", nonce: 0},
+				{kind: "acquire", nonce: 1},
+				{kind: "release", nonce: 1},
+				{kind: "acquire", nonce: 1},
+			},
+		},
+		{
+			name: "released gap is reused first",
+			ops: []op{
+				{kind: "acquire", nonce: 0},
+				{kind: "acquire", nonce: 1},
+				{kind: "acquire", nonce: 2},
+				{kind: "release", nonce: 0},
+				{kind: "acquire", nonce: 0},
+				{kind: "acquire", nonce: 3},
+			},
+		},
+		{
+			name: "reset resyncs with chain",
+			ops: []op{
+				{kind: "acquire", nonce: 0},
+				{kind: "done", nonce: 0},
+				{kind: "pending", nonce: 9},
+				{kind: "acquire", nonce: 1},
+				{kind: "reset"},
+				{kind: "acquire", nonce: 9},
+			},
+		},
+		{
+			name: "reset keeps nonces in flight",
+			ops: []op{
+				{kind: "pending", nonce: 3},
+				{kind: "acquire", nonce: 3},
+				{kind: "acquire", nonce: 4},
+				{kind: "reset"},
+				{kind: "acquire", nonce: 5},
+			},
+		},
+		{
+			name: "reset drops gaps used on chain",
+			ops: []op{
+				{kind: "acquire", nonce: 0},
+				{kind: "acquire", nonce: 1},
+				{kind: "acquire", nonce: 2},
+				{kind: "release", nonce: 0},
+				{kind: "done", nonce: 1},
+				{kind: "done", nonce: 2},
+				{kind: "pending", nonce: 3},
+				{kind: "reset"},
+				{kind: "acquire", nonce: 3},
+			},
+		},
+		{
+			name: "sync failure",
+			ops: []op{
+				{kind: "fail"},
+				{kind: "acquire", wantErr: true},
+				{kind: "pending", nonce: 2},
+				{kind: "acquire", nonce: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		m := newNonceManager(common.HexToAddress("0x1111111111111111111111111111111111111111"))
+		src := &testNonceSource{}
+		for i, o := range tt.ops {
+			switch o.kind {
+			case "pending":
+				src.pending, src.err = o.nonce, nil
+			case "fail":
+				src.err = errors.New("connection refused")
+			case "acquire":
+				got, err := m.Acquire(context.Background(), src)
+				if o.wantErr {
+					if err == nil {
+						t.Errorf("%s: op %d: Acquire = %d, want error", tt.name, i, got)
+					}
+					continue
+				}
+				if err != nil || got != o.nonce {
+					t.Errorf("%s: op %d: Acquire = %d, %v, want %d", tt.name, i, got, err, o.nonce)
+				}
+			case "release":
+				m.Release(o.nonce)
+			case "done":
+				m.Done(o.nonce)
+			case "reset":
+				m.Reset()
+			}
+		}
+	}
+}
+
+// TestNonceManagerTakeStaleGaps - 再利用されないまま残った返却済みNonceを取り出す
+func TestNonceManagerTakeStaleGaps(t *testing.T) {
+	m := newNonceManager(common.Address{})
+	src := &testNonceSource{}
+	for i := 0; i < 4; i++ {
+		if _, err := m.Acquire(context.Background(), src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Release(2)
+	m.Release(0)
+
+	if gaps := m.TakeStaleGaps(time.Hour); len(gaps) != 0 {
+		t.Errorf("TakeStaleGaps(1h) = %v, want none", gaps)
+	}
+	gaps := m.TakeStaleGaps(0)
+	if len(gaps) != 2 || gaps[0] != 0 || gaps[1] != 2 {
+		t.Errorf("TakeStaleGaps(0) = %v, want [0 2]", gaps)
+	}
+	// 取り出したNonceは払い出し済みなので、次は末尾から払い出す
+	if nonce, err := m.Acquire(context.Background(), src); err != nil || nonce != 4 {
+		t.Errorf("Acquire after TakeStaleGaps = %d, %v, want 4", nonce, err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MintPolicy - 1ウォレットあたりに発行できるトークン数のポリシー
+type MintPolicy struct {
+	// Max - 1ウォレットあたりの上限（0は無制限）
+	Max int64
+}
+
+// parseMintPolicy - MINT_POLICY の値を解析する
+// "unique"（1つまで）、"max:N"（N個まで）、"unlimited"（無制限）を受け付ける
+func parseMintPolicy(value string) (MintPolicy, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", "unique":
+		return MintPolicy{Max: 1}, nil
+	case "unlimited":
+		return MintPolicy{Max: 0}, nil
+	}
+
+	if n, ok := strings.CutPrefix(value, "max:"); ok {
+		max, err := strconv.ParseInt(n, 10, 64)
+		if err != nil || max < 1 {
+			return MintPolicy{}, fmt.Errorf("invalid mint policy %q: max must be a positive integer", value)
+		}
+		return MintPolicy{Max: max}, nil
+	}
+	return MintPolicy{}, fmt.Errorf("invalid mint policy %q: use unique, max:N or unlimited", value)
+}
+
+// String - ログ出力用
+func (p MintPolicy) String() string {
+	switch p.Max {
+	case 0:
+		return "unlimited"
+	case 1:
+		return "unique"
+	default:
+		return fmt.Sprintf("max:%d", p.Max)
+	}
+}
+
+// unlimited - 上限が無いかどうか
+func (p MintPolicy) unlimited() bool {
+	return p.Max == 0
+}
+
+// remaining - 保有数 held に対してあと何個Mintできるか（負の値なら無制限）
+func (p MintPolicy) remaining(held int64) int {
+	if p.unlimited() {
+		return -1
+	}
+	if held >= p.Max {
+		return 0
+	}
+	return int(p.Max - held)
+}
+
+// TokenBalance - ウォレットが保有するIdentitySBTの数をチェーンから取得する
+func (m *Minter) TokenBalance(ctx context.Context, walletAddress string) (int64, error) {
+	_, instance, err := m.connect(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	balance, err := instance.BalanceOf(&bind.CallOpts{Context: ctx}, common.HexToAddress(walletAddress))
+	if err := m.observe(err); err != nil {
+		return 0, fmt.Errorf("failed to get token balance: %w", err)
+	}
+	return balance.Int64(), nil
+}
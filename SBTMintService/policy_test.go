@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+// TestParseMintPolicy - MINT_POLICY の各表記と不正な値
+func TestParseMintPolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "", want: 1},
+		{value: "unique", want: 1},
+		{value: " Unique ", want: 1},
+		{value: "unlimited", want: 0},
+		{value: "max:3", want: 3},
+		{value: "MAX:10", want: 10},
+		{value: "max:0", wantErr: true},
+		{value: "max:-1", wantErr: true},
+		{value: "max:", wantErr: true},
+		{value: "max:two", wantErr: true},
+		{value: "once", wantErr: true},
+	}
+	for _, tt := range tests {
+		policy, err := parseMintPolicy(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseMintPolicy(%q) = %v, want error", tt.value, policy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMintPolicy(%q) returned error: %v", tt.value, err)
+			continue
+		}
+		if policy.Max != tt.want {
+			t.Errorf("parseMintPolicy(%q).Max = %d, want %d", tt.value, policy.Max, tt.want)
+		}
+	}
+}
+
+// TestMintPolicyRemaining - 保有数に対する残りのMint回数
+func TestMintPolicyRemaining(t *testing.T) {
+	tests := []struct {
+		max  int64
+		held int64
+		want int
+	}{
+		{max: 0, held: 0, want: -1},
+		{max: 0, held: 5, want: -1},
+		{max: 1, held: 0, want: 1},
+		{max: 1, held: 1, want: 0},
+		{max: 3, held: 1, want: 2},
+		{max: 3, held: 4, want: 0},
+	}
+	for _, tt := range tests {
+		if got := (MintPolicy{Max: tt.max}).remaining(tt.held); got != tt.want {
+			t.Errorf("MintPolicy{Max: %d}.remaining(%d) = %d, want %d", tt.max, tt.held, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// queryTimeout - 参照系エンドポイントでRPCを待つ最大時間
+const queryTimeout = 10 * time.Second
+
+// QueryResponse - 参照系エンドポイントのエラーレスポンス
+type QueryResponse struct {
+	Success   bool   `json:"success"`
+	ErrorCode string `json:"errorCode,omitempty"`
+	Message   string `json:"message"`
+}
+
+// TokenResponse - GET /tokens/{id} のレスポンス
+type TokenResponse struct {
+	Success  bool   `json:"success"`
+	TokenID  string `json:"tokenId"`
+	Owner    string `json:"owner"`
+	TokenURI string `json:"tokenUri"`
+	// MintBlock / MintTime / MintTxHash - インデックス済みの場合のみ
+	MintBlock  uint64     `json:"mintBlock,omitempty"`
+	MintTime   *time.Time `json:"mintTime,omitempty"`
+	MintTxHash string     `json:"mintTxHash,omitempty"`
+}
+
+// WalletTokensResponse - GET /wallets/{address}/tokens のレスポンス
+type WalletTokensResponse struct {
+	Success bool   `json:"success"`
+	Address string `json:"address"`
+	// Balance - チェーン上の保有数（BalanceOf）
+	Balance int64 `json:"balance"`
+	// TokenIDs - インデックスから取得した保有トークン（インデックスが追いつくまでBalanceより少ないことがある）
+	TokenIDs []string `json:"tokenIds"`
+	// Indexed - インデックスが最新ブロックまで追いついているか
+	Indexed bool `json:"indexed"`
+}
+
+// ContractResponse - GET /contract のレスポンス
+type ContractResponse struct {
+	Success bool   `json:"success"`
+	Address string `json:"address"`
+	ChainID int64  `json:"chainId"`
+	Name    string `json:"name"`
+	Symbol  string `json:"symbol"`
+	Owner   string `json:"owner"`
+}
+
+// queryCache - 参照系レスポンスの短期キャッシュ（RPCへの問い合わせを減らす）
+// 件数の上限を超えると最も長く使われていないものから捨てる（期限切れは読み出し時に捨てる）
+type queryCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // 先頭が最近使われたもの
+}
+
+// queryCacheEntry - キャッシュの1件
+type queryCacheEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// newQueryCache - ttl だけ値を保持し、最大 maxEntries 件までのキャッシュを作成（どちらかが0以下ならキャッシュしない）
+func newQueryCache(ttl time.Duration, maxEntries int) *queryCache {
+	return &queryCache{ttl: ttl, maxEntries: maxEntries, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// get - 期限内の値を返す
+func (c *queryCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*queryCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.value, true
+}
+
+// put - 値を保存し、上限を超えた分を古いものから捨てる
+func (c *queryCache) put(key string, value interface{}) {
+	if c.ttl <= 0 || c.maxEntries <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt := time.Now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*queryCacheEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&queryCacheEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove - 1件を捨てる（c.mu を保持して呼ぶ）
+func (c *queryCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*queryCacheEntry).key)
+}
+
+// TokenInfo - トークンの所有者とトークンURIをチェーンから取得する
+func (m *Minter) TokenInfo(ctx context.Context, tokenID *big.Int) (common.Address, string, error) {
+	_, instance, err := m.connect(ctx)
+	if err != nil {
+		return common.Address{}, "", err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	owner, err := instance.OwnerOf(opts, tokenID)
+	if err := m.observe(err); err != nil {
+		return common.Address{}, "", fmt.Errorf("failed to get token owner: %w", err)
+	}
+	uri, err := instance.TokenURI(opts, tokenID)
+	if err := m.observe(err); err != nil {
+		return common.Address{}, "", fmt.Errorf("failed to get token URI: %w", err)
+	}
+	return owner, uri, nil
+}
+
+// ContractInfo - コントラクトの名前・シンボル・所有者をチェーンから取得する
+func (m *Minter) ContractInfo(ctx context.Context) (ContractResponse, error) {
+	_, instance, err := m.connect(ctx)
+	if err != nil {
+		return ContractResponse{}, err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	name, err := instance.Name(opts)
+	if err := m.observe(err); err != nil {
+		return ContractResponse{}, fmt.Errorf("failed to get contract name: %w", err)
+	}
+	symbol, err := instance.Symbol(opts)
+	if err := m.observe(err); err != nil {
+		return ContractResponse{}, fmt.Errorf("failed to get contract symbol: %w", err)
+	}
+	owner, err := instance.Owner(opts)
+	if err := m.observe(err); err != nil {
+		return ContractResponse{}, fmt.Errorf("failed to get contract owner: %w", err)
+	}
+	return ContractResponse{
+		Success: true,
+		Address: m.contract.Hex(),
+		ChainID: m.chainID.Int64(),
+		Name:    name,
+		Symbol:  symbol,
+		Owner:   owner.Hex(),
+	}, nil
+}
+
+// tokenHandler - トークンの所有者とURIを返す
+func tokenHandler(w http.ResponseWriter, r *http.Request) {
+	// CORSヘッダーを設定
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// OPTIONSリクエスト（プリフライト）の処理
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	tokenID, ok := new(big.Int).SetString(strings.TrimSpace(r.PathValue("id")), 10)
+	if !ok || tokenID.Sign() < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success:   false,
+			ErrorCode: errCodeInvalidTokenID,
+			Message:   "Invalid token ID",
+		})
+		return
+	}
+
+	cacheKey := "token:" + tokenID.String()
+	if cached, ok := responseCache.get(cacheKey); ok {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(cached)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+	owner, uri, err := minter.TokenInfo(ctx, tokenID)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error loading token", "tokenId", tokenID, "error", err)
+		writeQueryError(w, err)
+		return
+	}
+
+	resp := TokenResponse{
+		Success:  true,
+		TokenID:  tokenID.String(),
+		Owner:    owner.Hex(),
+		TokenURI: uri,
+	}
+	if record, err := tokenIndex.Token(tokenID); err == nil {
+		resp.MintBlock = record.MintBlock
+		resp.MintTime = &record.MintTime
+		resp.MintTxHash = record.MintTxHash
+	}
+	responseCache.put(cacheKey, resp)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// walletTokensHandler - ウォレットの保有数と保有トークンIDを返す
+func walletTokensHandler(w http.ResponseWriter, r *http.Request) {
+	// CORSヘッダーを設定
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// OPTIONSリクエスト（プリフライト）の処理
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	address := strings.TrimSpace(r.PathValue("address"))
+	if !common.IsHexAddress(address) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success:   false,
+			ErrorCode: errCodeInvalidWallet,
+			Message:   "Invalid wallet address",
+		})
+		return
+	}
+	wallet := common.HexToAddress(address)
+
+	cacheKey := "wallet:" + wallet.Hex()
+	if cached, ok := responseCache.get(cacheKey); ok {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(cached)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+	balance, err := minter.TokenBalance(ctx, wallet.Hex())
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error loading token balance", "wallet", wallet.Hex(), "error", err)
+		writeQueryError(w, err)
+		return
+	}
+	records, err := tokenIndex.TokensOf(wallet)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error loading indexed tokens", "wallet", wallet.Hex(), "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Failed to load indexed tokens",
+		})
+		return
+	}
+
+	resp := WalletTokensResponse{
+		Success:  true,
+		Address:  wallet.Hex(),
+		Balance:  balance,
+		TokenIDs: make([]string, 0, len(records)),
+		Indexed:  tokenIndex.Status().Synced,
+	}
+	for _, record := range records {
+		resp.TokenIDs = append(resp.TokenIDs, record.TokenID)
+	}
+	responseCache.put(cacheKey, resp)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// contractHandler - コントラクトの名前・シンボル・所有者を返す
+func contractHandler(w http.ResponseWriter, r *http.Request) {
+	// CORSヘッダーを設定
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// OPTIONSリクエスト（プリフライト）の処理
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	const cacheKey = "contract"
+	if cached, ok := responseCache.get(cacheKey); ok {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(cached)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+	resp, err := minter.ContractInfo(ctx)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error loading contract info", "error", err)
+		writeQueryError(w, err)
+		return
+	}
+	responseCache.put(cacheKey, resp)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeQueryError - チェーンへの問い合わせの失敗をエラーコード付きで返す
+func writeQueryError(w http.ResponseWriter, err error) {
+	code, _ := classifyMintError(err)
+	status := errorCodeStatus(code)
+	message := errorCodeMessage(code)
+	if code == errCodeMintFailed {
+		// 参照系ではMint失敗ではなくRPCの失敗として返す
+		code = errCodeRPCUnavailable
+		status = http.StatusBadGateway
+		message = "Failed to query the blockchain"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(QueryResponse{
+		Success:   false,
+		ErrorCode: code,
+		Message:   message,
+	})
+}
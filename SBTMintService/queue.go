@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// noSignerRetryDelay - 使える署名者がいない場合にジョブを再試行するまでの時間
+const noSignerRetryDelay = 30 * time.Second
+
+// MintQueue - Mintジョブを非同期に処理するキュー
+type MintQueue struct {
+	store   *JobStore
+	minter  *Minter
+	pending chan string
+
+	mu       sync.Mutex
+	waiters  map[string][]chan *MintJob
+	trackers map[string]context.CancelFunc // レシート待ち中のジョブ
+}
+
+// newMintQueue - キューを作成
+func newMintQueue(store *JobStore, minter *Minter) *MintQueue {
+	return &MintQueue{
+		store:    store,
+		minter:   minter,
+		pending:  make(chan string, 256),
+		waiters:  make(map[string][]chan *MintJob),
+		trackers: make(map[string]context.CancelFunc),
+	}
+}
+
+// Start - ワーカーを起動し、前回の実行で終わらなかったジョブを再開する
+func (q *MintQueue) Start(workers int) error {
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+
+	jobs, err := q.store.Unfinished()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		observeJobStatus("", job.Status)
+		switch job.Status {
+		case jobStatusQueued:
+			jobLogger(job).Info("Resuming queued mint job")
+			q.schedule(job.ID)
+		case jobStatusSubmitted:
+			jobLogger(job).Info("Resuming submitted mint job", "txHash", job.TxHash)
+			if signer, ok := job.signerAddress(); ok {
+				q.minter.pool.Assign(job.ID, signer)
+			}
+			go q.track(job.ID, true)
+		}
+	}
+	return nil
+}
+
+// Enqueue - 新しいMintジョブを登録
+// idempotencyKey が既に使われている場合は新しいジョブを作らず元のジョブを返す（2つ目の戻り値がfalse）
+// credentialType はアテステーションで確認したVerified IDの種類（無ければ空）
+// maxPending は同じウォレットに許す未完了ジョブ数（負の値なら無制限）
+// ctx のリクエストIDとトレースコンテキストをジョブに記録し、非同期の処理のログとスパンにも付ける
+func (q *MintQueue) Enqueue(ctx context.Context, walletAddress, idempotencyKey, credentialType string, maxPending int) (*MintJob, bool, error) {
+	job, created, err := q.store.CreateJob(newMintJob(ctx, walletAddress, idempotencyKey, credentialType), maxPending)
+	if err != nil {
+		return job, false, err
+	}
+	if created {
+		observeJobStatus("", job.Status)
+		q.schedule(job.ID)
+	}
+	return job, created, nil
+}
+
+// EnqueueBatch - 一括Mintの宛先のジョブと一括Mintを1つのトランザクションで登録する
+// 同じIdempotency-Keyの一括Mintが既にあれば何も登録せずそのIDを返す
+func (q *MintQueue) EnqueueBatch(batch *MintBatch, admissions []*batchAdmission) (string, error) {
+	existing, err := q.store.CreateBatch(batch, admissions)
+	if err != nil || existing != "" {
+		return existing, err
+	}
+	for _, a := range admissions {
+		if a.created {
+			observeJobStatus("", a.job.Status)
+			q.schedule(a.job.ID)
+		}
+	}
+	return "", nil
+}
+
+// newMintJob - ctx のリクエストIDとトレースコンテキストを付けた新しいジョブを作る
+func newMintJob(ctx context.Context, walletAddress, idempotencyKey, credentialType string) *MintJob {
+	now := time.Now().UTC()
+	return &MintJob{
+		ID:             uuid.NewString(),
+		WalletAddress:  walletAddress,
+		IdempotencyKey: idempotencyKey,
+		CredentialType: credentialType,
+		CorrelationID:  requestIDFrom(ctx),
+		TraceContext:   traceCarrier(ctx),
+		Status:         jobStatusQueued,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// Wait - ジョブが終了するかctxが終わるまで待ち、その時点のジョブを返す
+func (q *MintQueue) Wait(ctx context.Context, id string) (*MintJob, error) {
+	ch := make(chan *MintJob, 1)
+	q.mu.Lock()
+	q.waiters[id] = append(q.waiters[id], ch)
+	q.mu.Unlock()
+	defer q.removeWaiter(id, ch)
+
+	// 登録前に終了している場合に備えて再確認
+	job, err := q.store.Get(id)
+	if err != nil || job.finished() {
+		return job, err
+	}
+
+	select {
+	case job := <-ch:
+		return job, nil
+	case <-ctx.Done():
+		return q.store.Get(id)
+	}
+}
+
+// removeWaiter - 待機を終えたリクエストのチャネルを通知先から外す（通知済みなら何もしない）
+func (q *MintQueue) removeWaiter(id string, ch chan *MintJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	waiters := slices.DeleteFunc(q.waiters[id], func(c chan *MintJob) bool { return c == ch })
+	if len(waiters) == 0 {
+		delete(q.waiters, id)
+	} else {
+		q.waiters[id] = waiters
+	}
+}
+
+// schedule - ジョブIDをワーカーに渡す（キューが満杯でも呼び出し元をブロックしない）
+func (q *MintQueue) schedule(id string) {
+	select {
+	case q.pending <- id:
+	default:
+		go func() { q.pending <- id }()
+	}
+}
+
+// worker - キューからジョブを取り出して送信する
+func (q *MintQueue) worker() {
+	for id := range q.pending {
+		q.process(id)
+	}
+}
+
+// process - ジョブのトランザクションを署名・送信する
+func (q *MintQueue) process(id string) {
+	job, err := q.store.Get(id)
+	if err != nil {
+		slog.Error("Failed to load mint job", "jobId", id, "error", err)
+		return
+	}
+	if job.Status != jobStatusQueued {
+		return
+	}
+	logger := jobLogger(job)
+	ctx, span := startJobSpan(withLogger(context.Background(), logger), "mint.job.send", job)
+	defer span.End()
+
+	// 署名者固有のエラー（Mint権限・残高不足）の場合は別の署名者でやり直す
+	for attempt := 1; ; attempt++ {
+		signer, err := q.minter.pool.Acquire(id)
+		if err != nil {
+			// 排出中・残高不足の署名者しかいない間はジョブをキューに残す
+			logger.Warn("No signer available, retrying later", "retryIn", noSignerRetryDelay.String())
+			time.AfterFunc(noSignerRetryDelay, func() { q.schedule(id) })
+			span.AddEvent("no signer available")
+			return
+		}
+		span.SetAttributes(attribute.String("mint.signer", signer.Address().Hex()))
+
+		// 署名済みトランザクションを送信前に保存し、再起動時の二重Mintを防ぐ
+		_, err = q.minter.MintSBT(ctx, signer, job.WalletAddress, func(tx *types.Transaction) error {
+			raw, err := tx.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			_, err = q.update(id, func(j *MintJob) {
+				j.Status = jobStatusSubmitted
+				j.Signer = signer.Address().Hex()
+				j.TxHash = tx.Hash().Hex()
+				j.RawTx = hexutil.Encode(raw)
+				j.BroadcastAt = time.Now().UTC()
+			})
+			return err
+		})
+		if err == nil {
+			break
+		}
+
+		if code, _ := classifyMintError(err); signerSpecificError(code) {
+			q.minter.pool.MarkFailed(signer.Address(), err)
+			if attempt < q.minter.pool.Len() {
+				logger.Warn("Signer cannot mint, trying another signer", "signer", signer.Address().Hex(), "errorCode", code)
+				q.minter.pool.Release(id)
+				// ノードが受け付けなかったトランザクションの記録を消してキューに戻す
+				if _, err := q.update(id, func(j *MintJob) {
+					j.Status = jobStatusQueued
+					j.Signer, j.TxHash, j.RawTx = "", "", ""
+					j.BroadcastAt = time.Time{}
+				}); err != nil {
+					logger.Error("Failed to update mint job", "error", err)
+					return
+				}
+				continue
+			}
+		}
+		logger.Error("Error minting SBT", "error", err)
+		recordError(span, err)
+		q.finish(id, func(j *MintJob) {
+			j.fail(err)
+		})
+		return
+	}
+
+	go q.track(id, false)
+}
+
+// signerSpecificError - 署名者を変えれば成功する可能性のあるエラーか
+func signerSpecificError(code string) bool {
+	return code == errCodeUnauthorizedMinter || code == errCodeInsufficientFunds
+}
+
+// track - 送信済みジョブのレシートを待って結果を記録する
+func (q *MintQueue) track(id string, rebroadcast bool) {
+	job, err := q.store.Get(id)
+	if err != nil {
+		slog.Error("Failed to load mint job", "jobId", id, "error", err)
+		return
+	}
+	logger := jobLogger(job)
+	ctx, span := startJobSpan(withLogger(context.Background(), logger), "mint.job.confirm", job)
+	defer span.End()
+
+	ctx, cancel := context.WithCancel(ctx)
+	q.mu.Lock()
+	q.trackers[id] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.trackers, id)
+		q.mu.Unlock()
+		cancel()
+	}()
+
+	// 送信前に停止した可能性があるため再送信する
+	if rebroadcast {
+		q.minter.rebroadcast(ctx, job.RawTx)
+	}
+
+	// 手数料の引き上げで置き換えられることがあるため、毎回ストアから最新のハッシュを読む
+	result, err := q.minter.WaitForMint(ctx, func() []common.Hash {
+		if current, err := q.store.Get(id); err == nil {
+			job = current
+		}
+		return job.txHashes()
+	})
+	switch {
+	case ctx.Err() != nil:
+		// abandon で打ち切られた
+		return
+	case errors.Is(err, errMintReverted):
+		logger.Warn("SBT mint reverted", "txHash", job.TxHash)
+		recordError(span, err)
+		q.finish(id, func(j *MintJob) {
+			j.fail(err)
+			j.Reverted = true
+			j.applyResult(result)
+		})
+	case err != nil:
+		logger.Error("Error waiting for mint job", "txHash", job.TxHash, "error", err)
+		recordError(span, err)
+		q.finish(id, func(j *MintJob) {
+			j.fail(err)
+		})
+	default:
+		q.finish(id, func(j *MintJob) {
+			j.Status = jobStatusConfirmed
+			j.applyResult(result)
+		})
+	}
+}
+
+// abandon - レシート待ちを打ち切り、ジョブを失敗として記録する
+func (q *MintQueue) abandon(id, reason string) {
+	q.mu.Lock()
+	cancel := q.trackers[id]
+	q.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+
+	q.finish(id, func(j *MintJob) {
+		j.Status = jobStatusFailed
+		j.Error = reason
+		j.ErrorCode = errCodeTransactionDropped
+	})
+}
+
+// update - ジョブを変更して保存し、状態が変わっていれば未完了ジョブ数のメトリクスに反映する
+func (q *MintQueue) update(id string, fn func(*MintJob)) (*MintJob, error) {
+	var from string
+	job, err := q.store.Update(id, func(j *MintJob) {
+		from = j.Status
+		fn(j)
+	})
+	if err != nil {
+		return nil, err
+	}
+	observeJobStatus(from, job.Status)
+	return job, nil
+}
+
+// finish - ジョブを終了状態にして待機中のリクエストに通知し、署名者の割り当てを解除する
+func (q *MintQueue) finish(id string, fn func(*MintJob)) {
+	job, err := q.update(id, fn)
+	if err != nil {
+		slog.Error("Failed to update mint job", "jobId", id, "error", err)
+		return
+	}
+	q.minter.pool.Release(id)
+	observeFinishedJob(job)
+
+	q.mu.Lock()
+	waiters := q.waiters[id]
+	delete(q.waiters, id)
+	q.mu.Unlock()
+
+	for _, ch := range waiters {
+		ch <- job
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// rateLimitsBucket - レート制限のキー → rateBucket
+var rateLimitsBucket = []byte("rateLimits")
+
+// RateLimit - トークンバケットの設定（Period ごとに Burst 回まで）
+type RateLimit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// RateLimitConfig - /mint のレート制限の設定（Burst が0の制限は無効）
+type RateLimitConfig struct {
+	Client RateLimit // APIキーごと
+	IP     RateLimit // 送信元IPごと
+	Wallet RateLimit // 受取ウォレットごと
+	Nonce  RateLimit // /siwe/nonce の送信元IPごと
+	// ClientIPHeader - プロキシが設定する送信元IPのヘッダー（fly.ioでは Fly-Client-IP）
+	ClientIPHeader string
+}
+
+// rateBucket - 保存するトークンバケットの状態
+type rateBucket struct {
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RateLimiter - キーごとのトークンバケット（再起動後も制限が続くようジョブストアに保存する）
+type RateLimiter struct {
+	db  *bolt.DB
+	cfg RateLimitConfig
+}
+
+// rateLimitKey - 制限の対象と設定
+type rateLimitKey struct {
+	key   string
+	limit RateLimit
+}
+
+// parseRateLimit - "N/period"（例: 10/m, 100/h, 5/30s）を解析する。空・"off" は無効
+func parseRateLimit(value string) (RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "off" {
+		return RateLimit{}, nil
+	}
+	count, period, ok := strings.Cut(value, "/")
+	burst, err := strconv.Atoi(count)
+	if !ok || err != nil || burst < 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: use N/period (e.g. 10/m)", value)
+	}
+	switch period {
+	case "s":
+		period = "1s"
+	case "m":
+		period = "1m"
+	case "h":
+		period = "1h"
+	case "d":
+		period = "24h"
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit period %q", period)
+	}
+	return RateLimit{Burst: burst, Period: d}, nil
+}
+
+// String - ログ用の表記
+func (l RateLimit) String() string {
+	if l.Burst == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period)
+}
+
+// newRateLimiter - ジョブストアと同じデータベースにバケットを保存する
+func newRateLimiter(db *bolt.DB, cfg RateLimitConfig) (*RateLimiter, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(rateLimitsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize rate limit store: %w", err)
+	}
+	return &RateLimiter{db: db, cfg: cfg}, nil
+}
+
+// AllowMint - 呼び出し元・送信元IP・受取ウォレットの全てのバケットにトークンがあれば1つずつ消費する
+// いずれかが空の場合はどれも消費せず、対象のキーと再試行までの時間を返す
+func (l *RateLimiter) AllowMint(r *http.Request, walletAddress string) (bool, string, time.Duration, error) {
+	keys := []rateLimitKey{
+		{"client:" + clientID(r), l.cfg.Client},
+		{"ip:" + l.clientIP(r), l.cfg.IP},
+		{"wallet:" + strings.ToLower(walletAddress), l.cfg.Wallet},
+	}
+	return l.take(keys, time.Now())
+}
+
+// AllowRequest - 呼び出し元・送信元IPのバケットのトークンを1つずつ消費する（一括Mintのリクエスト単位の制限）
+func (l *RateLimiter) AllowRequest(r *http.Request) (bool, string, time.Duration, error) {
+	keys := []rateLimitKey{
+		{"client:" + clientID(r), l.cfg.Client},
+		{"ip:" + l.clientIP(r), l.cfg.IP},
+	}
+	return l.take(keys, time.Now())
+}
+
+// AllowWallet - 受取ウォレットのバケットのトークンを1つ消費する（一括Mintの宛先単位の制限）
+func (l *RateLimiter) AllowWallet(walletAddress string) (bool, string, time.Duration, error) {
+	keys := []rateLimitKey{
+		{"wallet:" + strings.ToLower(walletAddress), l.cfg.Wallet},
+	}
+	return l.take(keys, time.Now())
+}
+
+// RefundWallet - AllowWallet で消費した受取ウォレットのトークンを1つ戻す（ジョブを登録しなかった一括Mintの宛先）
+func (l *RateLimiter) RefundWallet(walletAddress string) error {
+	return l.refund(rateLimitKey{"wallet:" + strings.ToLower(walletAddress), l.cfg.Wallet}, time.Now())
+}
+
+// AllowNonce - 送信元IPの /siwe/nonce のバケットのトークンを1つ消費する（認証不要のNonce発行の制限）
+func (l *RateLimiter) AllowNonce(r *http.Request) (bool, string, time.Duration, error) {
+	keys := []rateLimitKey{
+		{"nonce-ip:" + l.clientIP(r), l.cfg.Nonce},
+	}
+	return l.take(keys, time.Now())
+}
+
+// take - バケットを補充してからトークンを消費する
+func (l *RateLimiter) take(keys []rateLimitKey, now time.Time) (bool, string, time.Duration, error) {
+	allowed := true
+	var limitedKey string
+	var retryAfter time.Duration
+
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(rateLimitsBucket)
+		updated := make(map[string]rateBucket, len(keys))
+		for _, k := range keys {
+			if k.limit.Burst == 0 {
+				continue
+			}
+			bucket := refill(b.Get([]byte(k.key)), k.limit, now)
+			if bucket.Tokens < 1 {
+				// 1トークン分が補充されるまでの時間
+				wait := time.Duration((1 - bucket.Tokens) * float64(k.limit.Period) / float64(k.limit.Burst))
+				if wait > retryAfter {
+					retryAfter = wait
+					limitedKey = k.key
+				}
+				allowed = false
+				continue
+			}
+			bucket.Tokens--
+			updated[k.key] = bucket
+		}
+		if !allowed {
+			return nil
+		}
+		for key, bucket := range updated {
+			data, err := json.Marshal(bucket)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, "", 0, fmt.Errorf("failed to update rate limits: %w", err)
+	}
+	return allowed, limitedKey, retryAfter, nil
+}
+
+// refund - バケットを補充してからトークンを1つ戻す（容量は超えない）
+func (l *RateLimiter) refund(k rateLimitKey, now time.Time) error {
+	if k.limit.Burst == 0 {
+		return nil
+	}
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(rateLimitsBucket)
+		bucket := refill(b.Get([]byte(k.key)), k.limit, now)
+		bucket.Tokens = math.Min(float64(k.limit.Burst), bucket.Tokens+1)
+		data, err := json.Marshal(bucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(k.key), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update rate limits: %w", err)
+	}
+	return nil
+}
+
+// refill - 保存された状態から現在のトークン数を計算する（未保存なら満タン）
+func refill(data []byte, limit RateLimit, now time.Time) rateBucket {
+	capacity := float64(limit.Burst)
+	var bucket rateBucket
+	if data == nil || json.Unmarshal(data, &bucket) != nil {
+		return rateBucket{Tokens: capacity, UpdatedAt: now}
+	}
+	// 時計が戻った場合は補充しない
+	if elapsed := now.Sub(bucket.UpdatedAt); elapsed > 0 {
+		bucket.Tokens = math.Min(capacity, bucket.Tokens+elapsed.Seconds()*capacity/limit.Period.Seconds())
+	}
+	bucket.UpdatedAt = now
+	return bucket
+}
+
+// clientIP - 送信元IP（ClientIPHeader が設定されていればプロキシのヘッダーを使う）
+// X-Forwarded-For のように複数のアドレスを持つヘッダーでは、手前のアドレスはクライアントが自由に
+// 設定できるため、信頼するプロキシが最後に追加したアドレスを使う
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.cfg.ClientIPHeader != "" {
+		if values := r.Header.Values(l.cfg.ClientIPHeader); len(values) > 0 {
+			hops := strings.Split(values[len(values)-1], ",")
+			if ip := net.ParseIP(strings.TrimSpace(hops[len(hops)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// pruneLoop - 満タンまで補充されたバケットを定期的に削除する（削除しても結果は変わらない）
+func (l *RateLimiter) pruneLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := l.prune(time.Now()); err != nil {
+			slog.Error("Failed to prune rate limits", "error", err)
+		}
+	}
+}
+
+// prune - 最後の更新から設定期間以上経過したバケットを削除する
+func (l *RateLimiter) prune(now time.Time) error {
+	// 最も長い期間が過ぎれば、どの制限のバケットも満タンになっている
+	var maxPeriod time.Duration
+	for _, limit := range []RateLimit{l.cfg.Client, l.cfg.IP, l.cfg.Wallet, l.cfg.Nonce} {
+		maxPeriod = max(maxPeriod, limit.Period)
+	}
+	return l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(rateLimitsBucket)
+		var stale [][]byte
+		err := b.ForEach(func(k, data []byte) error {
+			var bucket rateBucket
+			if json.Unmarshal(data, &bucket) != nil || now.Sub(bucket.UpdatedAt) >= maxPeriod {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
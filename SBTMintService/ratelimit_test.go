@@ -0,0 +1,189 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestParseRateLimit - "N/period" の各表記と不正な値
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RateLimit
+		wantErr bool
+	}{
+		{value: "", want: RateLimit{}},
+		{value: "off", want: RateLimit{}},
+		{value: "10/s", want: RateLimit{Burst: 10, Period: time.Second}},
+		{value: "60/m", want: RateLimit{Burst: 60, Period: time.Minute}},
+		{value: " 5/h ", want: RateLimit{Burst: 5, Period: time.Hour}},
+		{value: "100/d", want: RateLimit{Burst: 100, Period: 24 * time.Hour}},
+		{value: "5/30s", want: RateLimit{Burst: 5, Period: 30 * time.Second}},
+		{value: "10", wantErr: true},
+		{value: "x/m", wantErr: true},
+		{value: "-1/m", wantErr: true},
+		{value: "10/w", wantErr: true},
+		{value: "10/0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseRateLimit(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseRateLimit(%q) = %v, want error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRateLimit(%q) returned error: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseRateLimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestRefill - 経過時間に応じた補充と上限
+func TestRefill(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := RateLimit{Burst: 10, Period: time.Minute}
+	saved := func(tokens float64, at time.Time) []byte {
+		data, err := json.Marshal(rateBucket{Tokens: tokens, UpdatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want float64
+	}{
+		{name: "new bucket is full", data: nil, want: 10},
+		{name: "corrupt bucket is full", data: []byte("{"), want: 10},
+		{name: "no time elapsed", data: saved(2, now), want: 2},
+		{name: "half period refills half", data: saved(2, now.Add(-30*time.Second)), want: 7},
+		{name: "capped at burst", data: saved(8, now.Add(-time.Hour)), want: 10},
+		{name: "clock moved back", data: saved(3, now.Add(time.Minute)), want: 3},
+	}
+	for _, tt := range tests {
+		got := refill(tt.data, limit, now)
+		if got.Tokens != tt.want {
+			t.Errorf("%s: tokens = %v, want %v", tt.name, got.Tokens, tt.want)
+		}
+		if !got.UpdatedAt.Equal(now) {
+			t.Errorf("%s: updatedAt = %v, want %v", tt.name, got.UpdatedAt, now)
+		}
+	}
+}
+
+// TestRateLimiterTake - いずれかのバケットが空なら全てのバケットを消費せずに拒否する
+func TestRateLimiterTake(t *testing.T) {
+	limiter, err := newRateLimiter(openTestStore(t).db, RateLimitConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := rateLimitKey{"client:a", RateLimit{Burst: 3, Period: time.Minute}}
+	wallet := rateLimitKey{"wallet:b", RateLimit{Burst: 1, Period: time.Hour}}
+	off := rateLimitKey{"ip:c", RateLimit{}}
+
+	steps := []struct {
+		keys       []rateLimitKey
+		at         time.Duration
+		allowed    bool
+		limitedKey string
+		retryAfter time.Duration
+	}{
+		{keys: []rateLimitKey{client, wallet, off}, allowed: true},
+		{keys: []rateLimitKey{client, wallet, off}, allowed: false, limitedKey: "wallet:b", retryAfter: time.Hour},
+		// 拒否されたリクエストでは client のトークンを消費しない
+		{keys: []rateLimitKey{client}, allowed: true},
+		{keys: []rateLimitKey{client}, allowed: true},
+		{keys: []rateLimitKey{client}, allowed: false, limitedKey: "client:a", retryAfter: 20 * time.Second},
+		{keys: []rateLimitKey{client}, at: 20 * time.Second, allowed: true},
+		{keys: []rateLimitKey{off}, allowed: true},
+	}
+	for i, step := range steps {
+		allowed, key, retryAfter, err := limiter.take(step.keys, now.Add(step.at))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if allowed != step.allowed || key != step.limitedKey || retryAfter.Round(time.Millisecond) != step.retryAfter {
+			t.Errorf("step %d: take = %v, %q, %v, want %v, %q, %v",
+				i, allowed, key, retryAfter, step.allowed, step.limitedKey, step.retryAfter)
+		}
+	}
+}
+
+// TestRateLimiterRefund - 戻したトークンは再び使え、容量を超えて戻さない
+func TestRateLimiterRefund(t *testing.T) {
+	limiter, err := newRateLimiter(openTestStore(t).db, RateLimitConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := rateLimitKey{"wallet:b", RateLimit{Burst: 1, Period: time.Hour}}
+	take := func() bool {
+		t.Helper()
+		allowed, _, _, err := limiter.take([]rateLimitKey{wallet}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return allowed
+	}
+
+	if !take() || take() {
+		t.Fatal("want the first take allowed and the second limited")
+	}
+	if err := limiter.refund(wallet, now); err != nil {
+		t.Fatal(err)
+	}
+	if !take() {
+		t.Error("take after refund was limited, want allowed")
+	}
+
+	// 満タンのバケットに戻しても1回分しか使えない
+	for range 2 {
+		if err := limiter.refund(wallet, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !take() || take() {
+		t.Error("refunds exceeded the bucket capacity")
+	}
+}
+
+// TestClientIP - プロキシのヘッダーは最後のアドレスを使い、無い・不正な場合は接続元アドレスにする
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		values []string
+		want   string
+	}{
+		{name: "no header configured", values: []string{"203.0.113.7"}, want: "192.0.2.1"},
+		{name: "fly client ip", header: "Fly-Client-IP", values: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "spoofed forwarded entry", header: "X-Forwarded-For", values: []string{"198.51.100.9, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "appended header line", header: "X-Forwarded-For", values: []string{"198.51.100.9", "203.0.113.7"}, want: "203.0.113.7"},
+		{name: "ipv6", header: "Fly-Client-IP", values: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "missing header", header: "Fly-Client-IP", want: "192.0.2.1"},
+		{name: "not an address", header: "Fly-Client-IP", values: []string{"evil"}, want: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		l := &RateLimiter{cfg: RateLimitConfig{ClientIPHeader: tt.header}}
+		r := httptest.NewRequest(http.MethodPost, "/mint", nil)
+		r.RemoteAddr = "192.0.2.1:54321"
+		for _, v := range tt.values {
+			r.Header.Add(cmp.Or(tt.header, "X-Forwarded-For"), v)
+		}
+		if got := l.clientIP(r); got != tt.want {
+			t.Errorf("%s: clientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
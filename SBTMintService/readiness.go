@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// readyTimeout - /readyz のチェック全体を待つ最大時間
+const readyTimeout = 5 * time.Second
+
+// readinessチェックの名前（ReadinessCheck.Name）
+const (
+	checkRPC      = "rpc"      // RPCに接続できる
+	checkChainID  = "chainId"  // チェーンIDが BLOCKCHAIN_CHAIN_ID と一致する
+	checkContract = "contract" // contractAddress にコードがある
+	checkMinter   = "minter"   // 排出中でない全ての署名者が safeMint を呼び出せる
+	checkBalance  = "balance"  // Mintできる残高の署名者がいる
+	checkStartup  = "startup"  // 起動時の検証に失敗して読み取り専用モードで起動していない
+)
+
+// ReadinessCheck - 個々のチェックの結果
+type ReadinessCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ReadinessResponse - GET /readyz のレスポンス
+type ReadinessResponse struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// Readiness - Mintを受け付けられる状態かをチェックする
+func (m *Minter) Readiness(ctx context.Context) []ReadinessCheck {
+	return append(m.chainChecks(ctx), m.balanceCheck())
+}
+
+// chainChecks - RPC接続・チェーンID・コントラクトのコード・所有者をチェックする
+// RPCに接続できない場合、チェーンに問い合わせるチェックは実行せずに失敗とする
+func (m *Minter) chainChecks(ctx context.Context) []ReadinessCheck {
+	checks := make([]ReadinessCheck, 0, 4)
+	skip := func(names ...string) {
+		for _, name := range names {
+			checks = append(checks, ReadinessCheck{Name: name, Detail: "skipped: RPC is unreachable"})
+		}
+	}
+
+	client, _, err := m.connect(ctx)
+	if err == nil {
+		_, err = client.BlockNumber(ctx)
+		err = m.observe(err)
+	}
+	if err != nil {
+		checks = append(checks, ReadinessCheck{Name: checkRPC, Detail: err.Error()})
+		skip(checkChainID, checkContract, checkMinter)
+		return checks
+	}
+	checks = append(checks, ReadinessCheck{Name: checkRPC, OK: true})
+
+	checks = append(checks, m.chainIDCheck(ctx, client))
+	contract := m.contractCheck(ctx, client)
+	checks = append(checks, contract)
+	if contract.OK {
+		checks = append(checks, m.minterCheck(ctx, client))
+	} else {
+		checks = append(checks, ReadinessCheck{Name: checkMinter, Detail: "skipped: no contract at address"})
+	}
+	return checks
+}
+
+// chainIDCheck - ノードのチェーンIDが設定と一致するか
+func (m *Minter) chainIDCheck(ctx context.Context, client chainClient) ReadinessCheck {
+	id, err := client.ChainID(ctx)
+	if err := m.observe(err); err != nil {
+		return ReadinessCheck{Name: checkChainID, Detail: err.Error()}
+	}
+	if id.Cmp(m.chainID) != 0 {
+		return ReadinessCheck{Name: checkChainID, Detail: fmt.Sprintf("node is on chain %s, expected %s", id, m.chainID)}
+	}
+	return ReadinessCheck{Name: checkChainID, OK: true, Detail: id.String()}
+}
+
+// contractCheck - コントラクトのアドレスにコードがあるか
+func (m *Minter) contractCheck(ctx context.Context, client chainClient) ReadinessCheck {
+	code, err := client.CodeAt(ctx, m.contract, nil)
+	if err := m.observe(err); err != nil {
+		return ReadinessCheck{Name: checkContract, Detail: err.Error()}
+	}
+	if len(code) == 0 {
+		return ReadinessCheck{Name: checkContract, Detail: fmt.Sprintf("no contract code at %s", m.contract.Hex())}
+	}
+	return ReadinessCheck{Name: checkContract, OK: true, Detail: m.contract.Hex()}
+}
+
+// minterCheck - 排出中でない全ての署名者が safeMint を呼び出せるか（eth_call で確認する）
+// IdentitySBT の safeMint は onlyOwner のため、所有者でない署名者が含まれるプールでは
+// その署名者に割り当てたMintが必ずrevertするので、1つでも呼び出せない署名者があれば失敗とする
+func (m *Minter) minterCheck(ctx context.Context, client chainClient) ReadinessCheck {
+	var active, denied []string
+	for _, status := range m.pool.Status() {
+		if status.Draining {
+			continue
+		}
+		active = append(active, status.Address)
+		allowed, err := m.canMint(ctx, client, common.HexToAddress(status.Address))
+		if err != nil {
+			return ReadinessCheck{Name: checkMinter, Detail: fmt.Sprintf("failed to check %s: %v", status.Address, err)}
+		}
+		if !allowed {
+			denied = append(denied, status.Address)
+		}
+	}
+	if len(active) == 0 {
+		return ReadinessCheck{Name: checkMinter, Detail: "all signers are draining"}
+	}
+	if len(denied) > 0 {
+		return ReadinessCheck{Name: checkMinter, Detail: fmt.Sprintf("signers not allowed to call safeMint (every active signer must be able to mint): %s",
+			strings.Join(denied, ", "))}
+	}
+	return ReadinessCheck{Name: checkMinter, OK: true, Detail: strings.Join(active, ", ")}
+}
+
+// canMint - signer からの safeMint の eth_call が権限エラーでrevertしないか
+// 受取人は署名者自身とし、権限以外の理由のrevertは権限があるものとみなす
+func (m *Minter) canMint(ctx context.Context, client chainClient, signer common.Address) (bool, error) {
+	data, err := identitySBTABI.Pack("safeMint", signer)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode safeMint call: %w", err)
+	}
+	_, err = client.CallContract(ctx, ethereum.CallMsg{From: signer, To: &m.contract, Data: data}, nil)
+	if revertErr := decodeRevert(err); revertErr != nil {
+		m.observe(nil)
+		return contractErrorCodes[revertErr.Name] != errCodeUnauthorizedMinter, nil
+	}
+	if err := m.observe(err); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// balanceCheck - 残高監視で取得した残高でMintできる署名者がいるか（未取得なら失敗）
+func (m *Minter) balanceCheck() ReadinessCheck {
+	for _, status := range m.pool.Status() {
+		if !status.Draining && status.Balance == "" {
+			return ReadinessCheck{Name: checkBalance, Detail: fmt.Sprintf("balance of %s has not been checked yet", status.Address)}
+		}
+	}
+	funds := m.pool.Funds()
+	if !funds.Funded {
+		return ReadinessCheck{Name: checkBalance, Detail: "no signer has enough balance above the minimum to mint"}
+	}
+	detail := ""
+	if funds.RemainingMints != nil {
+		detail = fmt.Sprintf("about %d mints remaining", *funds.RemainingMints)
+	}
+	return ReadinessCheck{Name: checkBalance, OK: true, Detail: detail}
+}
+
+// healthzHandler - 生存確認（プロセスが応答できれば200、チェーンには問い合わせない）
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
+}
+
+// readyzHandler - Mintを受け付けられるかをチェックし、全て成功なら200、いずれかが失敗なら503を返す
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	resp := ReadinessResponse{Ready: true, Checks: minter.Readiness(ctx)}
+	if readOnlyReason != "" {
+		resp.Checks = append(resp.Checks, ReadinessCheck{Name: checkStartup, Detail: "read-only mode: " + readOnlyReason})
+	}
+	for _, check := range resp.Checks {
+		resp.Ready = resp.Ready && check.OK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
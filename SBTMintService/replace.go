@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// minFeeBumpPercent - ノードが置き換えトランザクションに要求する手数料の最低引き上げ率（geth既定値）
+const minFeeBumpPercent = 10
+
+// StuckConfig - 取り込まれないトランザクションの置き換え設定
+type StuckConfig struct {
+	// Timeout - 送信からこの時間が経っても取り込まれなければ置き換える
+	Timeout time.Duration
+	// BumpPercent - 置き換え時に手数料を引き上げる割合（%、minFeeBumpPercent以上）
+	BumpPercent int64
+	// MaxBumps - 1ジョブあたりの置き換え回数の上限
+	MaxBumps int
+}
+
+// replaceStuck - 送信から時間が経っても取り込まれないトランザクションを、同じNonceで手数料を引き上げて置き換える
+// 置き換え前のハッシュは ReplacedTxHashes に残り、どれが取り込まれてもジョブは完了する
+func (q *MintQueue) replaceStuck(ctx context.Context, client chainClient, job *MintJob, stuck StuckConfig) {
+	if stuck.Timeout <= 0 || time.Since(job.broadcastAt()) < stuck.Timeout {
+		return
+	}
+	if len(job.ReplacedTxHashes) >= stuck.MaxBumps {
+		return
+	}
+
+	logger := jobLogger(job)
+	tx, err := decodeRawTx(job.RawTx)
+	if err != nil {
+		logger.Error("Cannot replace transaction", "error", err)
+		return
+	}
+	replacement, err := q.minter.replaceTransaction(ctx, client, tx, stuck.BumpPercent)
+	if err != nil {
+		logger.Warn("Cannot replace stuck transaction", "txHash", job.TxHash, "error", err)
+		return
+	}
+	raw, err := replacement.MarshalBinary()
+	if err != nil {
+		logger.Warn("Cannot replace stuck transaction", "txHash", job.TxHash, "error", err)
+		return
+	}
+
+	// 送信前に保存する（送信後に停止しても置き換え後のハッシュを追跡できるように）
+	previous := job.TxHash
+	newHash := replacement.Hash().Hex()
+	updated, err := q.update(job.ID, func(j *MintJob) {
+		if j.Status != jobStatusSubmitted || j.TxHash != previous {
+			return
+		}
+		j.ReplacedTxHashes = append(j.ReplacedTxHashes, previous)
+		j.TxHash = newHash
+		j.RawTx = hexutil.Encode(raw)
+		j.BroadcastAt = time.Now().UTC()
+	})
+	if err != nil {
+		logger.Error("Failed to record replacement", "error", err)
+		return
+	}
+	if updated.TxHash != newHash {
+		// 保存までの間にジョブが完了した
+		return
+	}
+
+	err = q.minter.observe(client.SendTransaction(ctx, replacement))
+	if err == nil || isAlreadyKnown(err) {
+		logger.Info("Replaced stuck transaction",
+			"previousTxHash", previous, "txHash", newHash, "nonce", replacement.Nonce(), "fees", feesOf(replacement))
+		return
+	}
+
+	// 送信できなかった置き換えは記録から外す（nonce too low なら元のトランザクションが取り込まれている）
+	logger.Warn("Failed to send replacement", "txHash", previous, "error", err)
+	if _, err := q.update(job.ID, func(j *MintJob) {
+		if j.TxHash != newHash {
+			return
+		}
+		j.ReplacedTxHashes = j.ReplacedTxHashes[:len(j.ReplacedTxHashes)-1]
+		j.TxHash = job.TxHash
+		j.RawTx = job.RawTx
+		j.BroadcastAt = job.BroadcastAt
+	}); err != nil {
+		logger.Error("Failed to roll back replacement", "error", err)
+	}
+}
+
+// replaceTransaction - tx と同じ署名者・Nonce・宛先・データで手数料を引き上げたトランザクションに署名する
+// 引き上げた手数料が設定の上限を超える場合は errFeeCapExceeded を返す
+func (m *Minter) replaceTransaction(ctx context.Context, client chainClient, tx *types.Transaction, percent int64) (*types.Transaction, error) {
+	if percent < minFeeBumpPercent {
+		percent = minFeeBumpPercent
+	}
+	from, err := txSender(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover signer: %w", err)
+	}
+	// 設定から外された署名者のトランザクションは置き換えられない（取り込まれるのを待つ）
+	signer, err := m.pool.Get(from)
+	if err != nil {
+		return nil, err
+	}
+	suggested, err := m.suggestFees(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var txdata types.TxData
+	if tx.Type() == types.DynamicFeeTxType {
+		tip := maxBig(bumpFee(tx.GasTipCap(), percent), suggested.GasTipCap)
+		feeCap := maxBig(bumpFee(tx.GasFeeCap(), percent), suggested.GasFeeCap)
+		if max := m.fees.MaxPriorityFeePerGas; max != nil && tip.Cmp(max) > 0 {
+			return nil, fmt.Errorf("%w: bumped priority fee %s gwei, max priority fee %s gwei",
+				errFeeCapExceeded, formatGwei(tip), formatGwei(max))
+		}
+		if feeCap.Cmp(tip) < 0 {
+			feeCap = new(big.Int).Set(tip)
+		}
+		if max := m.fees.MaxFeePerGas; max != nil && feeCap.Cmp(max) > 0 {
+			return nil, fmt.Errorf("%w: bumped max fee %s gwei, max fee %s gwei",
+				errFeeCapExceeded, formatGwei(feeCap), formatGwei(max))
+		}
+		txdata = &types.DynamicFeeTx{
+			ChainID:   m.chainID,
+			Nonce:     tx.Nonce(),
+			To:        tx.To(),
+			Value:     tx.Value(),
+			Gas:       tx.Gas(),
+			GasTipCap: tip,
+			GasFeeCap: feeCap,
+			Data:      tx.Data(),
+		}
+	} else {
+		current := suggested.GasPrice
+		if suggested.dynamic() {
+			current = suggested.GasFeeCap
+		}
+		gasPrice := maxBig(bumpFee(tx.GasPrice(), percent), current)
+		if max := m.fees.MaxFeePerGas; max != nil && gasPrice.Cmp(max) > 0 {
+			return nil, fmt.Errorf("%w: bumped gas price %s gwei, max fee %s gwei",
+				errFeeCapExceeded, formatGwei(gasPrice), formatGwei(max))
+		}
+		txdata = &types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Gas:      tx.Gas(),
+			GasPrice: gasPrice,
+			Data:     tx.Data(),
+		}
+	}
+
+	signed, err := m.signTx(ctx, signer, txdata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign replacement: %w", err)
+	}
+	return signed, nil
+}
+
+// bumpFee - fee を percent% 引き上げる（切り上げ）
+func bumpFee(fee *big.Int, percent int64) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+percent))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// maxBig - 大きい方を返す（nilは無視）
+func maxBig(a, b *big.Int) *big.Int {
+	if b == nil || (a != nil && a.Cmp(b) >= 0) {
+		return a
+	}
+	return new(big.Int).Set(b)
+}
+
+// feesOf - トランザクションの手数料をログ用の文字列にする
+func feesOf(tx *types.Transaction) string {
+	if tx.Type() == types.DynamicFeeTxType {
+		return txFees{GasTipCap: tx.GasTipCap(), GasFeeCap: tx.GasFeeCap()}.String()
+	}
+	return txFees{GasPrice: tx.GasPrice()}.String()
+}
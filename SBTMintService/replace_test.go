@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+// TestBumpFee - 引き上げ後の手数料は切り上げる（ノードは10%以上の引き上げを要求する）
+func TestBumpFee(t *testing.T) {
+	tests := []struct {
+		fee     int64
+		percent int64
+		want    int64
+	}{
+		{fee: 0, percent: 15, want: 0},
+		{fee: 100, percent: 10, want: 110},
+		{fee: 100, percent: 15, want: 115},
+		{fee: 1, percent: 10, want: 2},
+		{fee: 9, percent: 10, want: 10},
+		{fee: 30_000_000_000, percent: 12, want: 33_600_000_000},
+		{fee: 30_000_000_001, percent: 10, want: 33_000_000_002},
+	}
+	for _, tt := range tests {
+		fee := big.NewInt(tt.fee)
+		got := bumpFee(fee, tt.percent)
+		if got.Int64() != tt.want {
+			t.Errorf("bumpFee(%d, %d) = %s, want %d", tt.fee, tt.percent, got, tt.want)
+		}
+		if fee.Int64() != tt.fee {
+			t.Errorf("bumpFee(%d, %d) modified its argument to %s", tt.fee, tt.percent, fee)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// identitySBTABI - IdentitySBTMetaData から解析したABI（カスタムエラーの解析に使う）
+var identitySBTABI = func() *abi.ABI {
+	parsed, err := IdentitySBTMetaData.GetAbi()
+	if err != nil {
+		panic(fmt.Sprintf("invalid IdentitySBT ABI: %v", err))
+	}
+	return parsed
+}()
+
+// RevertError - コントラクトがrevertした理由を解析したもの
+type RevertError struct {
+	// Name - カスタムエラー名（例: OwnableUnauthorizedAccount）。require の文字列は "Error"、不明な場合は空
+	Name string
+	// Params - エラーの引数（引数名 → 値）
+	Params map[string]string
+	// Reason - require / revert の文字列、または解析できなかった場合のノードのメッセージ
+	Reason string
+	// Data - revertデータ（16進）
+	Data string
+}
+
+// Error - 人が読める形式
+func (e *RevertError) Error() string {
+	switch {
+	case e.Name == "" && e.Reason == "":
+		return "execution reverted"
+	case e.Name == "" || e.Name == "Error":
+		return "execution reverted: " + e.Reason
+	}
+
+	inputs := identitySBTABI.Errors[e.Name].Inputs
+	args := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		args = append(args, fmt.Sprintf("%s=%s", input.Name, e.Params[input.Name]))
+	}
+	return fmt.Sprintf("execution reverted: %s(%s)", e.Name, strings.Join(args, ", "))
+}
+
+// decodeRevert - RPCエラーに含まれるrevertデータをIdentitySBTのABIで解析する
+// revertでないエラーの場合はnilを返す
+func decodeRevert(err error) *RevertError {
+	if err == nil {
+		return nil
+	}
+	var revertErr *RevertError
+	if errors.As(err, &revertErr) {
+		return revertErr
+	}
+
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		if strings.Contains(err.Error(), "execution reverted") {
+			return &RevertError{Reason: err.Error()}
+		}
+		return nil
+	}
+
+	hexData, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return &RevertError{Reason: dataErr.Error()}
+	}
+	data, decodeErr := hexutil.Decode(hexData)
+	if decodeErr != nil || len(data) < 4 {
+		return &RevertError{Reason: dataErr.Error(), Data: hexData}
+	}
+
+	// require の文字列や Panic(uint256)
+	if reason, unpackErr := abi.UnpackRevert(data); unpackErr == nil {
+		return &RevertError{Name: "Error", Reason: reason, Data: hexData}
+	}
+
+	// コントラクトのカスタムエラー
+	var selector [4]byte
+	copy(selector[:], data[:4])
+	abiErr, lookupErr := identitySBTABI.ErrorByID(selector)
+	if lookupErr != nil {
+		return &RevertError{Reason: dataErr.Error(), Data: hexData}
+	}
+	values, unpackErr := abiErr.Unpack(data)
+	if unpackErr != nil {
+		return &RevertError{Name: abiErr.Name, Reason: dataErr.Error(), Data: hexData}
+	}
+
+	params := make(map[string]string, len(abiErr.Inputs))
+	if args, ok := values.([]interface{}); ok {
+		for i, input := range abiErr.Inputs {
+			if i < len(args) {
+				params[input.Name] = formatABIValue(args[i])
+			}
+		}
+	}
+	return &RevertError{Name: abiErr.Name, Params: params, Data: hexData}
+}
+
+// formatABIValue - ABIの値を文字列にする
+func formatABIValue(v interface{}) string {
+	switch value := v.(type) {
+	case common.Address:
+		return value.Hex()
+	case *big.Int:
+		return value.String()
+	case []byte:
+		return hexutil.Encode(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// testDataError - ノードがrevertデータ付きで返すJSON-RPCエラー
+type testDataError struct {
+	data interface{}
+}
+
+func (e *testDataError) Error() string          { return "execution reverted" }
+func (e *testDataError) ErrorCode() int         { return 3 }
+func (e *testDataError) ErrorData() interface{} { return e.data }
+
+// revertData - IdentitySBTのカスタムエラーのrevertデータを作る
+func revertData(t *testing.T, name string, args ...interface{}) string {
+	t.Helper()
+	abiErr, ok := identitySBTABI.Errors[name]
+	if !ok {
+		t.Fatalf("unknown contract error %s", name)
+	}
+	packed, err := abiErr.Inputs.Pack(args...)
+	if err != nil {
+		t.Fatalf("failed to pack %s: %v", name, err)
+	}
+	return hexutil.Encode(append(abiErr.ID[:4:4], packed...))
+}
+
+// revertReason - require の文字列（Error(string)）のrevertデータを作る
+func revertReason(reason string) string {
+	data := []byte{0x08, 0xc3, 0x79, 0xa0}
+	data = append(data, common.LeftPadBytes(big.NewInt(32).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(int64(len(reason))).Bytes(), 32)...)
+	data = append(data, common.RightPadBytes([]byte(reason), (len(reason)+31)/32*32)...)
+	return hexutil.Encode(data)
+}
+
+// TestDecodeRevert - revertデータの解析
+func TestDecodeRevert(t *testing.T) {
+	account := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tests := []struct {
+		name       string
+		err        error
+		wantNil    bool
+		wantName   string
+		wantParams map[string]string
+		wantReason string
+	}{
+		{name: "nil", err: nil, wantNil: true},
+		{name: "not a revert", err: errors.New("connection refused"), wantNil: true},
+		{
+			name:       "custom error",
+			err:        &testDataError{data: revertData(t, "OwnableUnauthorizedAccount", account)},
+			wantName:   "OwnableUnauthorizedAccount",
+			wantParams: map[string]string{"account": account.Hex()},
+		},
+		{
+			name:       "custom error with token ID",
+			err:        fmt.Errorf("estimate gas: %w", &testDataError{data: revertData(t, "ERC721NonexistentToken", big.NewInt(42))}),
+			wantName:   "ERC721NonexistentToken",
+			wantParams: map[string]string{"tokenId": "42"},
+		},
+		{
+			name:       "require reason",
+			err:        &testDataError{data: revertReason("not allowed")},
+			wantName:   "Error",
+			wantReason: "not allowed",
+		},
+		{
+			name:       "unknown selector",
+			err:        &testDataError{data: "0xdeadbeef"},
+			wantReason: "execution reverted",
+		},
+		{
+			name:       "data is not a string",
+			err:        &testDataError{data: 1},
+			wantReason: "execution reverted",
+		},
+		{
+			name:       "message only",
+			err:        errors.New("execution reverted: paused"),
+			wantReason: "execution reverted: paused",
+		},
+		{
+			name:       "already decoded",
+			err:        fmt.Errorf("send: %w", &RevertError{Name: "ERC721InvalidReceiver", Params: map[string]string{"receiver": "0x0"}}),
+			wantName:   "ERC721InvalidReceiver",
+			wantParams: map[string]string{"receiver": "0x0"},
+		},
+	}
+	for _, tt := range tests {
+		got := decodeRevert(tt.err)
+		if tt.wantNil {
+			if got != nil {
+				t.Errorf("%s: decodeRevert = %+v, want nil", tt.name, got)
+			}
+			continue
+		}
+		if got == nil {
+			t.Errorf("%s: decodeRevert = nil", tt.name)
+			continue
+		}
+		if got.Name != tt.wantName || got.Reason != tt.wantReason {
+			t.Errorf("%s: decodeRevert = %q/%q, want %q/%q", tt.name, got.Name, got.Reason, tt.wantName, tt.wantReason)
+		}
+		if tt.wantParams != nil && !maps.Equal(got.Params, tt.wantParams) {
+			t.Errorf("%s: params = %v, want %v", tt.name, got.Params, tt.wantParams)
+		}
+	}
+}
+
+// TestClassifyMintError - Mint処理のエラーとエラーコードの対応
+func TestClassifyMintError(t *testing.T) {
+	receiver := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "unauthorized minter", err: &testDataError{data: revertData(t, "OwnableUnauthorizedAccount", receiver)}, want: errCodeUnauthorizedMinter},
+		{name: "invalid receiver", err: &testDataError{data: revertData(t, "ERC721InvalidReceiver", receiver)}, want: errCodeInvalidReceiver},
+		{name: "already minted", err: &testDataError{data: revertData(t, "ERC721InvalidSender", common.Address{})}, want: errCodeTokenAlreadyMinted},
+		{name: "require reason", err: &testDataError{data: revertReason("paused")}, want: errCodeExecutionReverted},
+		{name: "bare revert", err: errors.New("execution reverted"), want: errCodeExecutionReverted},
+		{name: "receipt reverted", err: fmt.Errorf("%w: tx 0x1", errMintReverted), want: errCodeTransactionReverted},
+		{name: "fee cap", err: fmt.Errorf("%w: 100 gwei", errFeeCapExceeded), want: errCodeFeeCapExceeded},
+		{name: "gas limit", err: fmt.Errorf("%w: 900000", errGasLimitExceeded), want: errCodeGasLimitExceeded},
+		{name: "insufficient funds", err: errors.New("Insufficient funds for gas * price + value"), want: errCodeInsufficientFunds},
+		{name: "connection", err: fmt.Errorf("post: %w", io.EOF), want: errCodeRPCUnavailable},
+		{name: "deadline", err: fmt.Errorf("send: %w", context.DeadlineExceeded), want: errCodeRPCUnavailable},
+		{name: "other", err: errors.New("nonce too high"), want: errCodeMintFailed},
+	}
+	for _, tt := range tests {
+		if got, _ := classifyMintError(tt.err); got != tt.want {
+			t.Errorf("%s: classifyMintError = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// require の文字列はパラメータとして返す
+	if _, params := classifyMintError(&testDataError{data: revertReason("paused")}); params["reason"] != "paused" {
+		t.Errorf("classifyMintError params = %v, want reason=paused", params)
+	}
+}
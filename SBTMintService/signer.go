@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// 署名者の種類（MINT_SIGNER）
+const (
+	signerKindEnv      = "env"      // PRIVATE_KEY の16進の秘密鍵
+	signerKindKeystore = "keystore" // go-ethereumの暗号化キーストアJSON
+	signerKindRemote   = "remote"   // Clef等のリモート署名サービス
+)
+
+// remoteSignerTimeout - リモート署名サービスの起動時の確認を待つ最大時間
+const remoteSignerTimeout = 10 * time.Second
+
+// Signer - トランザクションに署名する（秘密鍵をMinterから切り離す）
+type Signer interface {
+	// Address - 署名アドレス
+	Address() common.Address
+	// SignTx - 署名済みのトランザクションを返す
+	SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// SignerConfig - 署名者の設定（鍵・ファイル・アドレスはカンマ区切りで読むが、現在のコントラクトでは1つだけ）
+type SignerConfig struct {
+	Kind string
+	// PrivateKeys - env: 16進の秘密鍵
+	PrivateKeys []string
+	// KeystoreFiles / KeystorePassword - keystore: キーストアJSONのパスと共通のパスフレーズ
+	// KeystorePasswordFile - パスフレーズを書いたファイル（KeystorePassword より優先）
+	KeystoreFiles        []string
+	KeystorePassword     string
+	KeystorePasswordFile string
+	// RemoteURL / RemoteAddresses / RemoteMethod - remote: JSON-RPCのURL・署名アカウント・署名メソッド
+	RemoteURL       string
+	RemoteAddresses []string
+	RemoteMethod    string
+}
+
+// newSigners - 設定の種類に応じた署名者を作成する
+func newSigners(cfg SignerConfig) ([]Signer, error) {
+	var signers []Signer
+	switch cfg.Kind {
+	case signerKindEnv, "":
+		if len(cfg.PrivateKeys) == 0 {
+			return nil, errors.New("PRIVATE_KEY environment variable is not set")
+		}
+		for i, hexKey := range cfg.PrivateKeys {
+			key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid PRIVATE_KEY #%d: %w", i+1, err)
+			}
+			signers = append(signers, newKeySigner(key))
+		}
+	case signerKindKeystore:
+		if len(cfg.KeystoreFiles) == 0 {
+			return nil, errors.New("MINT_KEYSTORE_FILE is not set")
+		}
+		password := cfg.KeystorePassword
+		if cfg.KeystorePasswordFile != "" {
+			secret, err := os.ReadFile(cfg.KeystorePasswordFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read keystore password: %w", err)
+			}
+			password = strings.TrimRight(string(secret), "\r\n")
+		}
+		for _, path := range cfg.KeystoreFiles {
+			signer, err := openKeystoreSigner(path, password)
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, signer)
+		}
+	case signerKindRemote:
+		if len(cfg.RemoteAddresses) == 0 {
+			return nil, errors.New("MINT_REMOTE_SIGNER_ADDRESS is not set")
+		}
+		for _, address := range cfg.RemoteAddresses {
+			signer, err := newRemoteSigner(cfg.RemoteURL, address, cfg.RemoteMethod)
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, signer)
+		}
+	default:
+		return nil, fmt.Errorf("unknown signer %q (use env, keystore or remote)", cfg.Kind)
+	}
+	// IdentitySBTの safeMint は onlyOwner のため、Mintできるのはコントラクトの所有者1つだけ
+	// （2つ目以降の署名者は常に失敗し、全署名者のチェックを求める readiness も通らない）
+	if len(signers) > 1 {
+		return nil, fmt.Errorf("%d signers configured, but IdentitySBT.safeMint is onlyOwner: configure only the contract owner", len(signers))
+	}
+	return signers, nil
+}
+
+// transactOpts - Signer で署名する TransactOpts を作成する
+func transactOpts(ctx context.Context, signer Signer, chainID *big.Int) *bind.TransactOpts {
+	from := signer.Address()
+	return &bind.TransactOpts{
+		From:    from,
+		Context: ctx,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, bind.ErrNotAuthorized
+			}
+			return signer.SignTx(ctx, tx, chainID)
+		},
+	}
+}
+
+// keySigner - メモリ上の秘密鍵で署名する
+type keySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// newKeySigner - 秘密鍵から署名者を作成する
+func newKeySigner(key *ecdsa.PrivateKey) *keySigner {
+	return &keySigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// Address - 署名アドレス
+func (s *keySigner) Address() common.Address {
+	return s.address
+}
+
+// SignTx - 秘密鍵で署名する
+func (s *keySigner) SignTx(_ context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// openKeystoreSigner - 暗号化されたキーストアJSON（geth account new 等で作成）を復号する
+func openKeystoreSigner(path, password string) (*keySigner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	key, err := keystore.DecryptKey(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore %s: %w", path, err)
+	}
+	return newKeySigner(key.PrivateKey), nil
+}
+
+// remoteSigner - JSON-RPCの署名サービス（Clefの account_signTransaction または eth_signTransaction）で署名する
+type remoteSigner struct {
+	client  *rpc.Client
+	address common.Address
+	method  string
+}
+
+// remoteTxArgs - 署名を依頼するトランザクション（ClefのSendTxArgs / eth_signTransactionの引数）
+type remoteTxArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to,omitempty"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+}
+
+// newRemoteSigner - 署名サービスに接続し、address のアカウントを扱えるか確認する
+func newRemoteSigner(url, address, method string) (*remoteSigner, error) {
+	if url == "" {
+		return nil, errors.New("MINT_REMOTE_SIGNER_URL is not set")
+	}
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid MINT_REMOTE_SIGNER_ADDRESS %q", address)
+	}
+	if method == "" {
+		method = "account_signTransaction"
+	}
+	if method != "account_signTransaction" && method != "eth_signTransaction" {
+		return nil, fmt.Errorf("unsupported remote signer method %q", method)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+	client, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to remote signer: %w", err)
+	}
+	s := &remoteSigner{client: client, address: common.HexToAddress(address), method: method}
+
+	// Clefは account_list、それ以外は eth_accounts でアカウントを確認する
+	// （Clefは一覧の承認を求める場合があるので、確認できない場合は警告だけにする）
+	listMethod := "eth_accounts"
+	if method == "account_signTransaction" {
+		listMethod = "account_list"
+	}
+	var accounts []common.Address
+	if err := client.CallContext(ctx, &accounts, listMethod); err != nil {
+		slog.Warn("Could not list remote signer accounts", "error", err)
+	} else if !slices.Contains(accounts, s.address) {
+		client.Close()
+		return nil, fmt.Errorf("remote signer does not manage account %s", s.address.Hex())
+	}
+	return s, nil
+}
+
+// Address - 署名アドレス
+func (s *remoteSigner) Address() common.Address {
+	return s.address
+}
+
+// SignTx - 署名サービスに署名を依頼し、返されたトランザクションが依頼した内容と一致するか確認する
+func (s *remoteSigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := remoteTxArgs{
+		From:    s.address,
+		To:      tx.To(),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   (*hexutil.Big)(tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    tx.Data(),
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	if tx.Type() == types.LegacyTxType {
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	}
+
+	// Clefと geth は {raw, tx}、Web3Signer等は署名済みRLPの16進文字列を返す
+	var result json.RawMessage
+	if err := s.client.CallContext(ctx, &result, s.method, args); err != nil {
+		return nil, fmt.Errorf("remote signer rejected transaction: %w", err)
+	}
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err != nil {
+		var signed struct {
+			Raw hexutil.Bytes `json:"raw"`
+		}
+		if err := json.Unmarshal(result, &signed); err != nil || len(signed.Raw) == 0 {
+			return nil, errors.New("remote signer returned an unexpected response")
+		}
+		raw = signed.Raw
+	}
+
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("remote signer returned an invalid transaction: %w", err)
+	}
+	// 署名対象（Nonce・宛先・ガス・手数料・データ）が依頼と同じで、署名者が正しいこと
+	txSigner := types.LatestSignerForChainID(chainID)
+	if signed.Type() != tx.Type() || txSigner.Hash(signed) != txSigner.Hash(tx) {
+		return nil, errors.New("remote signer modified the transaction")
+	}
+	sender, err := types.Sender(txSigner, signed)
+	if err != nil || sender != s.address {
+		return nil, fmt.Errorf("remote signer signed with a different account")
+	}
+	return signed, nil
+}
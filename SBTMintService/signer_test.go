@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestNewSignersSingleOwner - 所有者しかMintできないため、署名者は1つだけ受け付ける
+func TestNewSignersSingleOwner(t *testing.T) {
+	var keys []string
+	for range 2 {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, hex.EncodeToString(crypto.FromECDSA(key)))
+	}
+
+	signers, err := newSigners(SignerConfig{Kind: signerKindEnv, PrivateKeys: keys[:1]})
+	if err != nil || len(signers) != 1 {
+		t.Fatalf("newSigners(1 key) = %d signers, %v, want 1", len(signers), err)
+	}
+	_, err = newSigners(SignerConfig{Kind: signerKindEnv, PrivateKeys: keys})
+	if err == nil || !strings.Contains(err.Error(), "onlyOwner") {
+		t.Errorf("newSigners(2 keys) error = %v, want the single owner limitation", err)
+	}
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	bolt "go.etcd.io/bbolt"
+)
+
+// signersBucket - 署名者のアドレス → signerState（排出中などの運用状態）
+var signersBucket = []byte("signers")
+
+// signerCooldown - 署名者固有のエラー（Mint権限・残高不足）の後、その署名者に割り当てない時間
+const signerCooldown = 5 * time.Minute
+
+var (
+	// errNoSigner - 新しいMintに使える署名者がいない（全て排出中・残高不足・エラー後の待機中）
+	errNoSigner = errors.New("no signer available")
+	// errUnknownSigner - プールに無い署名者
+	errUnknownSigner = errors.New("signer is not configured")
+)
+
+// poolSigner - プール内の署名者とそのNonce・残高・割り当て中のジョブ
+type poolSigner struct {
+	Signer
+	nonces *NonceManager
+
+	// 以下は SignerPool.mu で保護する
+	jobs           map[string]bool // 割り当て中のジョブ（署名待ち・レシート待ち）
+	balance        *big.Int
+	draining       bool
+	unhealthyUntil time.Time
+	lastError      string
+}
+
+// signerState - 再起動後も維持する署名者の運用状態
+type signerState struct {
+	Draining bool `json:"draining"`
+}
+
+// SignerStatus - 署名者の状態（/signers と /health）
+type SignerStatus struct {
+	Address    string `json:"address"`
+	ActiveJobs int    `json:"activeJobs"`
+	Balance    string `json:"balance,omitempty"` // wei（未取得なら空）
+	// RemainingMints - 残高のうち MinBalance を超える分で送信できるMintの推定回数（残高・Mint費用が未取得なら無し）
+	RemainingMints *uint64 `json:"remainingMints,omitempty"`
+	Draining       bool    `json:"draining"`
+	Available      bool    `json:"available"`
+	LastError      string  `json:"lastError,omitempty"`
+}
+
+// FundsStatus - 排出中でない署名者の残高の集計（/health）
+type FundsStatus struct {
+	// Funded - 新しいMintを受け付けられる残高の署名者がいるか
+	Funded   bool   `json:"funded"`
+	Floor    string `json:"floor,omitempty"`    // wei（MINT_SIGNER_MIN_BALANCE_POL）
+	MintCost string `json:"mintCost,omitempty"` // 1回のMintに必要な手数料の見積もり（wei）
+	// RemainingMints - 全署名者の残高で送信できるMintの推定回数
+	RemainingMints *uint64 `json:"remainingMints,omitempty"`
+}
+
+// balanceChange - refreshBalances で取得した残高の変化
+type balanceChange struct {
+	Address  common.Address
+	Previous *big.Int // 初回はnil
+	Current  *big.Int
+}
+
+// SignerPool - 複数の署名者にMintを振り分ける
+//
+// 新しいジョブは排出中でなく、残高が MinBalance に1回のMint費用の見積もりを加えた額以上で、直近にエラーの無い署名者のうち
+// 割り当て中のジョブが最も少ないものに割り当てる。Nonceは署名者ごとに管理する。
+// 排出中の署名者には新しいジョブを割り当てないが、送信済みのジョブは置き換え・ギャップ埋めを含めて最後まで処理する。
+type SignerPool struct {
+	minBalance *big.Int
+
+	mu        sync.Mutex
+	db        *bolt.DB
+	signers   []*poolSigner
+	byAddress map[common.Address]*poolSigner
+	mintCost  *big.Int // 1回のMintに必要な手数料の見積もり（未取得ならnil）
+}
+
+// newSignerPool - 署名者のプールを作成する（アドレスの重複はエラー）
+func newSignerPool(signers []Signer, minBalance *big.Int) (*SignerPool, error) {
+	if len(signers) == 0 {
+		return nil, errors.New("no signer configured")
+	}
+	p := &SignerPool{minBalance: minBalance, byAddress: make(map[common.Address]*poolSigner)}
+	for _, signer := range signers {
+		address := signer.Address()
+		if _, ok := p.byAddress[address]; ok {
+			return nil, fmt.Errorf("signer %s is configured twice", address.Hex())
+		}
+		s := &poolSigner{Signer: signer, nonces: newNonceManager(address), jobs: make(map[string]bool)}
+		p.signers = append(p.signers, s)
+		p.byAddress[address] = s
+	}
+	return p, nil
+}
+
+// Len - 署名者の数
+func (p *SignerPool) Len() int {
+	return len(p.signers)
+}
+
+// Addresses - 署名者のアドレス（設定順）
+func (p *SignerPool) Addresses() []common.Address {
+	addresses := make([]common.Address, len(p.signers))
+	for i, s := range p.signers {
+		addresses[i] = s.Address()
+	}
+	return addresses
+}
+
+// attachStore - 保存されている排出中の状態を読み込み、以後の変更を保存する
+func (p *SignerPool) attachStore(db *bolt.DB) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(signersBucket)
+		if err != nil {
+			return err
+		}
+		p.db = db
+		return b.ForEach(func(k, data []byte) error {
+			var state signerState
+			if err := json.Unmarshal(data, &state); err != nil {
+				return err
+			}
+			if s, ok := p.byAddress[common.HexToAddress(string(k))]; ok {
+				s.draining = state.Draining
+			}
+			return nil
+		})
+	})
+}
+
+// Acquire - ジョブを割り当て中のジョブが最も少ない利用可能な署名者に割り当てる
+func (p *SignerPool) Acquire(jobID string) (*poolSigner, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var best *poolSigner
+	for _, s := range p.signers {
+		if !p.available(s, now) {
+			continue
+		}
+		if best == nil || len(s.jobs) < len(best.jobs) {
+			best = s
+		}
+	}
+	if best == nil {
+		return nil, errNoSigner
+	}
+	best.jobs[jobID] = true
+	return best, nil
+}
+
+// Assign - 再起動時に送信済みのジョブを署名者に割り当て直す（プールに無い署名者なら何もしない）
+func (p *SignerPool) Assign(jobID string, address common.Address) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.byAddress[address]; ok {
+		s.jobs[jobID] = true
+	}
+}
+
+// Release - ジョブの割り当てを解除する（割り当てが無ければ何もしない）
+func (p *SignerPool) Release(jobID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.signers {
+		delete(s.jobs, jobID)
+	}
+}
+
+// MarkFailed - 署名者固有のエラーの後、しばらくその署名者に割り当てない
+func (p *SignerPool) MarkFailed(address common.Address, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.byAddress[address]; ok {
+		s.unhealthyUntil = time.Now().Add(signerCooldown)
+		s.lastError = err.Error()
+	}
+}
+
+// Get - アドレスの署名者（排出中も含む）
+func (p *SignerPool) Get(address common.Address) (*poolSigner, error) {
+	s, ok := p.byAddress[address]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownSigner, address.Hex())
+	}
+	return s, nil
+}
+
+// SetDraining - 署名者への新しいジョブの割り当てを止める・再開する
+func (p *SignerPool) SetDraining(address common.Address, draining bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.byAddress[address]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownSigner, address.Hex())
+	}
+	if p.db != nil {
+		data, err := json.Marshal(signerState{Draining: draining})
+		if err != nil {
+			return err
+		}
+		err = p.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(signersBucket).Put([]byte(address.Hex()), data)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save signer state: %w", err)
+		}
+	}
+	s.draining = draining
+	return nil
+}
+
+// Status - 全署名者の状態
+func (p *SignerPool) Status() []SignerStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]SignerStatus, 0, len(p.signers))
+	for _, s := range p.signers {
+		status := SignerStatus{
+			Address:    s.Address().Hex(),
+			ActiveJobs: len(s.jobs),
+			Draining:   s.draining,
+			Available:  p.available(s, now),
+			LastError:  s.lastError,
+		}
+		if s.balance != nil {
+			status.Balance = s.balance.String()
+			status.RemainingMints = p.remainingMints(s.balance)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Funds - 排出中でない署名者の残高の集計
+func (p *SignerPool) Funds() FundsStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	funds := FundsStatus{Funded: p.funded()}
+	if p.minBalance != nil {
+		funds.Floor = p.minBalance.String()
+	}
+	if p.mintCost != nil {
+		funds.MintCost = p.mintCost.String()
+	}
+	var total uint64
+	for _, s := range p.signers {
+		if s.draining {
+			continue
+		}
+		if s.balance == nil {
+			return funds
+		}
+		remaining := p.remainingMints(s.balance)
+		if remaining == nil {
+			return funds
+		}
+		total += *remaining
+	}
+	funds.RemainingMints = &total
+	return funds
+}
+
+// Funded - 新しいMintを受け付けられる残高の署名者がいるか
+// 残高が未取得の署名者は受け付けられるものとみなす（全署名者が排出中の場合も、ジョブはキューで待つので受け付ける）
+func (p *SignerPool) Funded() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.funded()
+}
+
+// funded - Funded の本体（p.mu を保持して呼ぶ）
+func (p *SignerPool) funded() bool {
+	draining := 0
+	for _, s := range p.signers {
+		if s.draining {
+			draining++
+			continue
+		}
+		if p.sufficient(s) {
+			return true
+		}
+	}
+	return draining == len(p.signers)
+}
+
+// refreshBalances - 全署名者の残高を取得し、前回からの変化を返す
+func (p *SignerPool) refreshBalances(ctx context.Context, client chainClient) ([]balanceChange, error) {
+	changes := make([]balanceChange, 0, len(p.signers))
+	for _, s := range p.signers {
+		balance, err := client.BalanceAt(ctx, s.Address(), nil)
+		if err != nil {
+			return changes, fmt.Errorf("failed to get balance of %s: %w", s.Address().Hex(), err)
+		}
+		p.mu.Lock()
+		changes = append(changes, balanceChange{Address: s.Address(), Previous: s.balance, Current: balance})
+		s.balance = balance
+		p.mu.Unlock()
+	}
+	return changes, nil
+}
+
+// setMintCost - 1回のMintに必要な手数料の見積もりを更新する
+func (p *SignerPool) setMintCost(cost *big.Int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mintCost = cost
+}
+
+// available - 新しいジョブを割り当てられるか（p.mu を保持して呼ぶ）
+func (p *SignerPool) available(s *poolSigner, now time.Time) bool {
+	if s.draining || now.Before(s.unhealthyUntil) {
+		return false
+	}
+	return p.sufficient(s)
+}
+
+// sufficient - 残高が MinBalance 以上で、MinBalance を下回らずに1回Mintできるか（p.mu を保持して呼ぶ）
+// 残高が未取得の場合は割り当てる（送信時に残高不足なら MarkFailed される）
+func (p *SignerPool) sufficient(s *poolSigner) bool {
+	if s.balance == nil {
+		return true
+	}
+	if p.minBalance != nil && s.balance.Cmp(p.minBalance) < 0 {
+		return false
+	}
+	if remaining := p.remainingMints(s.balance); remaining != nil && *remaining == 0 {
+		return false
+	}
+	return true
+}
+
+// remainingMints - 残高のうち MinBalance を超える分で送信できるMintの推定回数
+// Mint費用が未取得ならnil（p.mu を保持して呼ぶ）
+func (p *SignerPool) remainingMints(balance *big.Int) *uint64 {
+	if p.mintCost == nil || p.mintCost.Sign() == 0 {
+		return nil
+	}
+	usable := new(big.Int).Set(balance)
+	if p.minBalance != nil {
+		usable.Sub(usable, p.minBalance)
+	}
+	var n uint64
+	if usable.Sign() > 0 {
+		n = new(big.Int).Quo(usable, p.mintCost).Uint64()
+	}
+	return &n
+}
+
+// txSender - 署名済みトランザクションの署名者
+func txSender(tx *types.Transaction) (common.Address, error) {
+	return types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+}
+
+// parsePOL - POL単位の文字列をweiに変換する
+func parsePOL(value string) (*big.Int, error) {
+	f, ok := new(big.Float).SetPrec(256).SetString(value)
+	if !ok || f.Sign() < 0 {
+		return nil, fmt.Errorf("invalid POL amount %q", value)
+	}
+	wei, _ := f.Mul(f, big.NewFloat(params.Ether)).Int(nil)
+	return wei, nil
+}
+
+// weiToPOL - weiをPOL単位の小数にする
+func weiToPOL(wei *big.Int) float64 {
+	pol, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether)).Float64()
+	return pol
+}
+
+// formatPOL - weiをPOL単位の文字列にする
+func formatPOL(wei *big.Int) string {
+	return fmt.Sprintf("%.4f POL", weiToPOL(wei))
+}
+
+// SignersResponse - GET /signers のレスポンス
+type SignersResponse struct {
+	Success bool           `json:"success"`
+	Signers []SignerStatus `json:"signers"`
+}
+
+// signersHandler - 署名者ごとの割り当て中のジョブ数・残高・排出状態を返す
+func signersHandler(w http.ResponseWriter, r *http.Request) {
+	// CORSヘッダーを設定
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// OPTIONSリクエスト（プリフライト）の処理
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SignersResponse{Success: true, Signers: minter.pool.Status()})
+}
+
+// drainSignerHandler - POSTで署名者を排出中にし（新しいMintを割り当てない）、DELETEで割り当てを再開する
+// 排出中の署名者の activeJobs が0になれば、送信済みのジョブを失わずに設定から外せる
+func drainSignerHandler(w http.ResponseWriter, r *http.Request) {
+	// CORSヘッダーを設定
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// OPTIONSリクエスト（プリフライト）の処理
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	address := r.PathValue("address")
+	if !common.IsHexAddress(address) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success:   false,
+			ErrorCode: errCodeInvalidWallet,
+			Message:   "Invalid signer address",
+		})
+		return
+	}
+	draining := r.Method == http.MethodPost
+	err := minter.pool.SetDraining(common.HexToAddress(address), draining)
+	if errors.Is(err, errUnknownSigner) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Signer is not configured",
+		})
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error updating signer", "signer", address, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Failed to update signer",
+		})
+		return
+	}
+	loggerFrom(r.Context()).Info("Signer drain state changed",
+		"signer", common.HexToAddress(address).Hex(), "draining", draining, "client", clientID(r))
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SignersResponse{Success: true, Signers: minter.pool.Status()})
+}
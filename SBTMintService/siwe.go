@@ -0,0 +1,393 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	bolt "go.etcd.io/bbolt"
+)
+
+// siweNoncesBucket - 発行したSIWEのNonce → siweNonce
+var siweNoncesBucket = []byte("siweNonces")
+
+// siweHeaderSuffix - EIP-4361メッセージの1行目の末尾
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+var (
+	// errSIWERequired - ウォレットの所有証明が必須なのに含まれていない
+	errSIWERequired = errors.New("sign-in with ethereum proof is required")
+	// errSIWEInvalid - メッセージ・署名・Nonceのいずれかが不正
+	errSIWEInvalid = errors.New("invalid sign-in with ethereum proof")
+)
+
+// SIWEProof - MintRequestに含めるEIP-4361のメッセージと署名
+type SIWEProof struct {
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+}
+
+// SIWEMessage - 解析したEIP-4361メッセージ
+type SIWEMessage struct {
+	Domain         string
+	Address        common.Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+}
+
+// SIWEConfig - ウォレットの所有証明の設定
+type SIWEConfig struct {
+	// Domains - 許可するメッセージのドメイン（フロントエンドのホスト、空なら制限しない）
+	Domains []string
+	// NonceTTL - Nonceの有効期間
+	NonceTTL time.Duration
+	// Required - 所有証明の無いMintリクエストを拒否する
+	Required bool
+}
+
+// siweNonce - 発行したNonceの状態
+type siweNonce struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	// UsedBy / UsedFor - 使用済みの場合のウォレットとIdempotency-Key（同じMintの再試行だけ許可する）
+	UsedBy  string `json:"usedBy,omitempty"`
+	UsedFor string `json:"usedFor,omitempty"`
+}
+
+// SIWEVerifier - EIP-4361のメッセージと署名を検証し、Nonceを1回だけ使わせる
+type SIWEVerifier struct {
+	db      *bolt.DB
+	chainID int64
+	cfg     SIWEConfig
+}
+
+// SIWENonceResponse - GET /siwe/nonce のレスポンス
+type SIWENonceResponse struct {
+	Nonce     string    `json:"nonce"`
+	ChainID   int64     `json:"chainId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// newSIWEVerifier - ジョブストアと同じデータベースにNonceを保存する
+func newSIWEVerifier(db *bolt.DB, chainID int64, cfg SIWEConfig) (*SIWEVerifier, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(siweNoncesBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize SIWE nonce store: %w", err)
+	}
+	return &SIWEVerifier{db: db, chainID: chainID, cfg: cfg}, nil
+}
+
+// Required - 所有証明が必須かどうか
+func (v *SIWEVerifier) Required() bool {
+	return v.cfg.Required
+}
+
+// IssueNonce - 新しいNonceを発行して保存する（期限切れのNonceは pruneLoop で削除する）
+func (v *SIWEVerifier) IssueNonce(now time.Time) (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	// EIP-4361のNonceは英数字8文字以上
+	nonce := hex.EncodeToString(buf)
+	expiresAt := now.Add(v.cfg.NonceTTL).UTC()
+
+	data, err := json.Marshal(siweNonce{ExpiresAt: expiresAt})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	err = v.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(siweNoncesBucket).Put([]byte(nonce), data)
+	})
+	return nonce, expiresAt, err
+}
+
+// pruneLoop - 期限切れのNonceを定期的に削除する
+func (v *SIWEVerifier) pruneLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := v.prune(time.Now()); err != nil {
+			slog.Error("Failed to prune SIWE nonces", "error", err)
+		}
+	}
+}
+
+// prune - 有効期限を過ぎたNonceを削除する（使用済みでも期限後は再試行を受け付けないため不要）
+func (v *SIWEVerifier) prune(now time.Time) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(siweNoncesBucket)
+		var expired [][]byte
+		err := b.ForEach(func(k, data []byte) error {
+			var n siweNonce
+			if json.Unmarshal(data, &n) != nil || now.After(n.ExpiresAt) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Verify - 署名者が wallet であることと、メッセージの内容・Nonceを検証する
+// Nonceは使用済みにするが、同じウォレット・同じIdempotency-Keyでの再試行は許可する
+func (v *SIWEVerifier) Verify(proof *SIWEProof, wallet common.Address, idempotencyKey string, now time.Time) (*SIWEMessage, error) {
+	if proof == nil || proof.Message == "" {
+		return nil, errSIWERequired
+	}
+	msg, err := parseSIWEMessage(proof.Message)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errSIWEInvalid, err)
+	}
+
+	signer, err := recoverSIWESigner(proof.Message, proof.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errSIWEInvalid, err)
+	}
+	switch {
+	case signer != msg.Address:
+		return nil, fmt.Errorf("%w: message is signed by %s, not %s", errSIWEInvalid, signer.Hex(), msg.Address.Hex())
+	case msg.Address != wallet:
+		return nil, fmt.Errorf("%w: message is for %s, not the recipient %s", errSIWEInvalid, msg.Address.Hex(), wallet.Hex())
+	case msg.Version != "1":
+		return nil, fmt.Errorf("%w: unsupported version %q", errSIWEInvalid, msg.Version)
+	case msg.ChainID != v.chainID:
+		return nil, fmt.Errorf("%w: chain ID %d does not match %d", errSIWEInvalid, msg.ChainID, v.chainID)
+	case len(v.cfg.Domains) > 0 && !slices.Contains(v.cfg.Domains, msg.Domain):
+		return nil, fmt.Errorf("%w: domain %q is not accepted", errSIWEInvalid, msg.Domain)
+	case msg.IssuedAt.After(now.Add(attestationLeeway)):
+		return nil, fmt.Errorf("%w: issued in the future", errSIWEInvalid)
+	case msg.ExpirationTime != nil && now.After(*msg.ExpirationTime):
+		return nil, fmt.Errorf("%w: message expired", errSIWEInvalid)
+	case msg.NotBefore != nil && now.Before(*msg.NotBefore):
+		return nil, fmt.Errorf("%w: message is not valid yet", errSIWEInvalid)
+	}
+
+	if err := v.consumeNonce(msg.Nonce, wallet, idempotencyKey, now); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// consumeNonce - Nonceを使用済みにする
+func (v *SIWEVerifier) consumeNonce(nonce string, wallet common.Address, idempotencyKey string, now time.Time) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(siweNoncesBucket)
+		data := b.Get([]byte(nonce))
+		if data == nil {
+			return fmt.Errorf("%w: unknown nonce", errSIWEInvalid)
+		}
+		var n siweNonce
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		if now.After(n.ExpiresAt) {
+			return fmt.Errorf("%w: nonce expired", errSIWEInvalid)
+		}
+		if n.UsedBy != "" {
+			// 同じMintの再試行（Idempotency-Keyが同じ）以外は再利用とみなす
+			if idempotencyKey != "" && n.UsedBy == wallet.Hex() && n.UsedFor == idempotencyKey {
+				return nil
+			}
+			return fmt.Errorf("%w: nonce already used", errSIWEInvalid)
+		}
+
+		n.UsedBy = wallet.Hex()
+		n.UsedFor = idempotencyKey
+		updated, err := json.Marshal(n)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(nonce), updated)
+	})
+}
+
+// parseSIWEMessage - EIP-4361のメッセージを解析する
+func parseSIWEMessage(message string) (*SIWEMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if len(lines) < 2 {
+		return nil, errors.New("message is too short")
+	}
+
+	domain, ok := strings.CutSuffix(lines[0], siweHeaderSuffix)
+	if !ok || domain == "" {
+		return nil, errors.New("missing EIP-4361 header")
+	}
+	if !common.IsHexAddress(lines[1]) {
+		return nil, errors.New("invalid address")
+	}
+	msg := &SIWEMessage{Domain: domain, Address: common.HexToAddress(lines[1])}
+
+	// アドレスの後は空行・ステートメント（任意）・空行に続いて "Key: value" が並ぶ
+	var err error
+	for _, line := range lines[2:] {
+		key, value, found := strings.Cut(line, ": ")
+		if !found {
+			if line != "" && !strings.HasPrefix(line, "- ") && line != "Resources:" && msg.URI == "" {
+				msg.Statement = line
+			}
+			continue
+		}
+		switch key {
+		case "URI":
+			msg.URI = value
+		case "Version":
+			msg.Version = value
+		case "Chain ID":
+			msg.ChainID, err = strconv.ParseInt(value, 10, 64)
+		case "Nonce":
+			msg.Nonce = value
+		case "Issued At":
+			msg.IssuedAt, err = time.Parse(time.RFC3339, value)
+		case "Expiration Time":
+			msg.ExpirationTime, err = parseSIWETime(value)
+		case "Not Before":
+			msg.NotBefore, err = parseSIWETime(value)
+		case "Request ID":
+			msg.RequestID = value
+		default:
+			if msg.URI == "" {
+				msg.Statement = line
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	switch {
+	case msg.URI == "":
+		return nil, errors.New("missing URI")
+	case msg.Version == "":
+		return nil, errors.New("missing Version")
+	case msg.ChainID == 0:
+		return nil, errors.New("missing Chain ID")
+	case len(msg.Nonce) < 8:
+		return nil, errors.New("missing or short Nonce")
+	case msg.IssuedAt.IsZero():
+		return nil, errors.New("missing Issued At")
+	}
+	return msg, nil
+}
+
+// parseSIWETime - RFC 3339の時刻を解析する
+func parseSIWETime(value string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// recoverSIWESigner - personal_sign（EIP-191）の署名から署名者のアドレスを復元する
+func recoverSIWESigner(message, signature string) (common.Address, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return common.Address{}, errors.New("invalid signature")
+	}
+	// ウォレットは v を 27/28 で返すので 0/1 に戻す
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover signer: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// siweNonceHandler - SIWEメッセージに埋め込むNonceを発行する
+func siweNonceHandler(w http.ResponseWriter, r *http.Request) {
+	// CORSヘッダーを設定
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
+
+	// OPTIONSリクエスト（プリフライト）の処理
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	// 認証不要のため送信元IPごとに発行数を制限する
+	allowed, _, retryAfter, err := rateLimiter.AllowNonce(r)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error checking rate limits", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Failed to check rate limits",
+		})
+		return
+	}
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		w.WriteHeader(errorCodeStatus(errCodeRateLimited))
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success:   false,
+			ErrorCode: errCodeRateLimited,
+			Message:   errorCodeMessage(errCodeRateLimited),
+		})
+		return
+	}
+
+	nonce, expiresAt, err := siwe.IssueNonce(time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Message: "Failed to issue nonce",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SIWENonceResponse{
+		Nonce:     nonce,
+		ChainID:   siwe.chainID,
+		ExpiresAt: expiresAt,
+	})
+}
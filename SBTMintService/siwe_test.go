@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// siweTestTime - テストのメッセージの発行時刻
+var siweTestTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// siweTestMessage - EIP-4361のメッセージを組み立てる（fields は "Key: value" の行）
+func siweTestMessage(domain string, address common.Address, fields ...string) string {
+	lines := []string{
+		domain + siweHeaderSuffix,
+		address.Hex(),
+		"",
+		"Mint your employee SBT",
+		"",
+	}
+	return strings.Join(append(lines, fields...), "\n")
+}
+
+// siweTestFields - 有効なメッセージの項目（overrides で同じキーの行を置き換える）
+func siweTestFields(nonce string, overrides ...string) []string {
+	fields := []string{
+		"URI: https://johnyamanaka.github.io/nft-poc/",
+		"Version: 1",
+		"Chain ID: 80002",
+		"Nonce: " + nonce,
+		"Issued At: " + siweTestTime.Format(time.RFC3339),
+	}
+	for _, o := range overrides {
+		key, _, _ := strings.Cut(o, ": ")
+		replaced := false
+		for i, f := range fields {
+			if strings.HasPrefix(f, key+": ") {
+				fields[i], replaced = o, true
+			}
+		}
+		if !replaced {
+			fields = append(fields, o)
+		}
+	}
+	return fields
+}
+
+// signSIWE - personal_sign と同じくv=27/28で署名する
+func signSIWE(t *testing.T, key *ecdsa.PrivateKey, message string) string {
+	t.Helper()
+	sig, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return hexutil.Encode(sig)
+}
+
+// TestParseSIWEMessage - EIP-4361のメッセージの解析
+func TestParseSIWEMessage(t *testing.T) {
+	address := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	expires := siweTestTime.Add(10 * time.Minute)
+
+	tests := []struct {
+		name    string
+		message string
+		wantErr string
+		check   func(*SIWEMessage) error
+	}{
+		{
+			name:    "minimal",
+			message: siweTestMessage("example.com", address, siweTestFields("abcdef123456")...),
+			check: func(m *SIWEMessage) error {
+				if m.Domain != "example.com" || m.Address != address || m.Statement != "Mint your employee SBT" ||
+					m.Version != "1" || m.ChainID != 80002 || m.Nonce != "abcdef123456" || !m.IssuedAt.Equal(siweTestTime) {
+					return fmt.Errorf("unexpected message %+v", m)
+				}
+				if m.ExpirationTime != nil || m.NotBefore != nil {
+					return errors.New("optional times should be nil")
+				}
+				return nil
+			},
+		},
+		{
+			name: "optional fields and CRLF",
+			message: strings.ReplaceAll(siweTestMessage("localhost:8000", address, siweTestFields("abcdef123456",
+				"Expiration Time: "+expires.Format(time.RFC3339),
+				"Not Before: "+siweTestTime.Format(time.RFC3339),
+				"Request ID: req-1",
+				"Resources:",
+				"- https://example.com/terms")...), "\n", "\r\n"),
+			check: func(m *SIWEMessage) error {
+				if m.Domain != "localhost:8000" || m.RequestID != "req-1" {
+					return fmt.Errorf("unexpected message %+v", m)
+				}
+				if m.ExpirationTime == nil || !m.ExpirationTime.Equal(expires) || m.NotBefore == nil || !m.NotBefore.Equal(siweTestTime) {
+					return fmt.Errorf("unexpected times %v / %v", m.ExpirationTime, m.NotBefore)
+				}
+				return nil
+			},
+		},
+		{name: "too short", message: "example.com" + siweHeaderSuffix, wantErr: "too short"},
+		{name: "missing header", message: "example.com wants you to sign in\n" + address.Hex(), wantErr: "header"},
+		{name: "invalid address", message: "example.com" + siweHeaderSuffix + "\n0x1234", wantErr: "address"},
+		{name: "missing URI", message: siweTestMessage("example.com", address, siweTestFields("abcdef123456")[1:]...), wantErr: "URI"},
+		{name: "short nonce", message: siweTestMessage("example.com", address, siweTestFields("abc")...), wantErr: "Nonce"},
+		{name: "invalid chain ID", message: siweTestMessage("example.com", address, siweTestFields("abcdef123456", "Chain ID: amoy")...), wantErr: "Chain ID"},
+		{name: "invalid issued at", message: siweTestMessage("example.com", address, siweTestFields("abcdef123456", "Issued At: yesterday")...), wantErr: "Issued At"},
+	}
+	for _, tt := range tests {
+		msg, err := parseSIWEMessage(tt.message)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: parseSIWEMessage error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseSIWEMessage returned error: %v", tt.name, err)
+			continue
+		}
+		if err := tt.check(msg); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+// TestSIWEVerify - 署名者・内容・Nonceの検証と、同じMintの再試行だけNonceの再利用を許すこと
+func TestSIWEVerify(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := crypto.PubkeyToAddress(key.PublicKey)
+
+	verifier, err := newSIWEVerifier(openTestStore(t).db, 80002, SIWEConfig{
+		Domains:  []string{"johnyamanaka.github.io"},
+		NonceTTL: 15 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func() string {
+		nonce, _, err := verifier.IssueNonce(siweTestTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nonce
+	}
+	proof := func(signer *ecdsa.PrivateKey, domain string, address common.Address, fields []string) *SIWEProof {
+		message := siweTestMessage(domain, address, fields...)
+		return &SIWEProof{Message: message, Signature: signSIWE(t, signer, message)}
+	}
+	now := siweTestTime.Add(time.Minute)
+
+	valid := issue()
+	reused := issue()
+	unused := issue()
+	expired := issue()
+	tests := []struct {
+		name    string
+		proof   *SIWEProof
+		wallet  common.Address
+		key     string
+		at      time.Time
+		wantErr error
+	}{
+		{name: "missing proof", proof: nil, wallet: wallet, wantErr: errSIWERequired},
+		{name: "valid", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(valid)), wallet: wallet, key: "key-1"},
+		{name: "retry with same key", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(valid)), wallet: wallet, key: "key-1"},
+		{name: "reuse with another key", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(valid)), wallet: wallet, key: "key-2", wantErr: errSIWEInvalid},
+		{name: "first use without key", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(reused)), wallet: wallet},
+		{name: "reuse without key", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(reused)), wallet: wallet, wantErr: errSIWEInvalid},
+		{name: "signed by another key", proof: proof(other, "johnyamanaka.github.io", wallet, siweTestFields(unused)), wallet: wallet, wantErr: errSIWEInvalid},
+		{name: "another recipient", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(unused)), wallet: common.HexToAddress("0x2222222222222222222222222222222222222222"), wantErr: errSIWEInvalid},
+		{name: "wrong chain", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(unused, "Chain ID: 1")), wallet: wallet, wantErr: errSIWEInvalid},
+		{name: "wrong version", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(unused, "Version: 2")), wallet: wallet, wantErr: errSIWEInvalid},
+		{name: "unknown domain", proof: proof(key, "evil.example", wallet, siweTestFields(unused)), wallet: wallet, wantErr: errSIWEInvalid},
+		{name: "issued in the future", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(unused)), wallet: wallet, at: siweTestTime.Add(-2 * time.Minute), wantErr: errSIWEInvalid},
+		{name: "message expired", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(unused, "Expiration Time: "+siweTestTime.Format(time.RFC3339))), wallet: wallet, wantErr: errSIWEInvalid},
+		{name: "not valid yet", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(unused, "Not Before: "+now.Add(time.Minute).Format(time.RFC3339))), wallet: wallet, wantErr: errSIWEInvalid},
+		{name: "unknown nonce", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields("0123456789abcdef")), wallet: wallet, wantErr: errSIWEInvalid},
+		{name: "nonce expired", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(expired)), wallet: wallet, at: siweTestTime.Add(16 * time.Minute), wantErr: errSIWEInvalid},
+		{name: "bad signature", proof: &SIWEProof{Message: siweTestMessage("johnyamanaka.github.io", wallet, siweTestFields(unused)...), Signature: "0x1234"}, wallet: wallet, wantErr: errSIWEInvalid},
+		{name: "unused nonce is still valid", proof: proof(key, "johnyamanaka.github.io", wallet, siweTestFields(unused)), wallet: wallet, key: "key-3"},
+	}
+	for _, tt := range tests {
+		at := now
+		if !tt.at.IsZero() {
+			at = tt.at
+		}
+		msg, err := verifier.Verify(tt.proof, tt.wallet, tt.key, at)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: Verify error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Verify returned error: %v", tt.name, err)
+			continue
+		}
+		if msg.Address != wallet {
+			t.Errorf("%s: Verify address = %s, want %s", tt.name, msg.Address.Hex(), wallet.Hex())
+		}
+	}
+
+	// 期限切れのNonceは削除される
+	if err := verifier.prune(siweTestTime.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	retry := proof(key, "johnyamanaka.github.io", wallet, siweTestFields(valid))
+	if _, err := verifier.Verify(retry, wallet, "key-1", siweTestTime.Add(time.Minute)); !errors.Is(err, errSIWEInvalid) {
+		t.Errorf("Verify after prune = %v, want errSIWEInvalid", err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// 起動時の検証に失敗した場合の動作（MINT_STARTUP_CHECK）
+const (
+	startupCheckFail     = "fail"     // 終了する
+	startupCheckReadOnly = "readonly" // 参照系だけを提供し、Mintを受け付けない
+	startupCheckOff      = "off"      // 検証しない
+)
+
+// startupRetryInterval - 起動時にRPCへ接続できない場合に再試行する間隔
+const startupRetryInterval = 2 * time.Second
+
+// checkStartup - RPC接続・チェーンID・コントラクトのコード・全ての署名者が safeMint を呼び出せることを確認する
+// RPCに接続できない間は timeout まで再試行し、失敗したチェックを返す
+func (m *Minter) checkStartup(timeout time.Duration) []ReadinessCheck {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for {
+		checks := m.chainChecks(ctx)
+		if checks[0].OK || ctx.Err() != nil {
+			return failedChecks(checks)
+		}
+		select {
+		case <-ctx.Done():
+			return failedChecks(checks)
+		case <-time.After(startupRetryInterval):
+		}
+	}
+}
+
+// failedChecks - 失敗したチェックだけを返す
+func failedChecks(checks []ReadinessCheck) []ReadinessCheck {
+	var failed []ReadinessCheck
+	for _, check := range checks {
+		if !check.OK {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// describeChecks - 失敗したチェックの診断メッセージ
+func describeChecks(checks []ReadinessCheck) string {
+	details := make([]string, len(checks))
+	for i, check := range checks {
+		details[i] = fmt.Sprintf("%s: %s", check.Name, check.Detail)
+	}
+	return strings.Join(details, "; ")
+}
+
+// validateStartup - 起動時の検証を行い、mode に応じて終了するか読み取り専用モードの理由を返す（問題なければ空）
+func (m *Minter) validateStartup(mode string, timeout time.Duration) string {
+	switch mode {
+	case startupCheckOff:
+		return ""
+	case startupCheckFail, startupCheckReadOnly:
+	default:
+		fatal("Invalid MINT_STARTUP_CHECK (use fail, readonly or off)", "value", mode)
+	}
+
+	failed := m.checkStartup(timeout)
+	if len(failed) == 0 {
+		slog.Info("Startup validation passed", "chainId", m.chainID.String(), "contract", m.contract.Hex())
+		return ""
+	}
+	for _, check := range failed {
+		slog.Error("Startup validation failed", "check", check.Name, "detail", check.Detail)
+	}
+	reason := describeChecks(failed)
+	if mode == startupCheckFail {
+		fatal("Refusing to start (set MINT_STARTUP_CHECK=readonly to start without minting)", "reason", reason)
+	}
+	slog.Warn("Starting in read-only mode: mints are disabled until the configuration is fixed and the service is restarted")
+	return reason
+}
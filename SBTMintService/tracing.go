@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName - OTEL_SERVICE_NAME が無い場合のトレースのサービス名
+const serviceName = "sbt-mint-service"
+
+// tracer - HTTP処理とMint処理のスパンを作るトレーサー（setupTracing で設定するまでは何も記録しない）
+var tracer = otel.Tracer("sbtmint")
+
+// setupTracing - W3C Trace Context の伝播を設定し、OTLPのエンドポイントが設定されていればスパンを送信する
+// エンドポイント・ヘッダー・サンプリング等は OpenTelemetry 標準の OTEL_* 環境変数で指定する
+// 戻り値の関数で未送信のスパンを送って終了する
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" ||
+		(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "") {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := newTracerProvider(ctx, exporter)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTracerProvider(provider)
+	slog.Info("Exporting traces via OTLP")
+	return provider.Shutdown, nil
+}
+
+// newTracerProvider - exporter にスパンを送るプロバイダーを作成する（テストではメモリ上のエクスポーターを渡せる）
+func newTracerProvider(ctx context.Context, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	// OTEL_SERVICE_NAME・OTEL_RESOURCE_ATTRIBUTES があればそちらを優先する
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// recordError - エラーがあればスパンに記録し、スパンの状態をエラーにする
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// endSpan - エラーがあればスパンに記録して終了する
+func endSpan(span trace.Span, err error) {
+	recordError(span, err)
+	span.End()
+}
+
+// rpcSpan - Mint処理のRPC呼び出しのスパン（終了時に所要時間のメトリクスも記録する）
+type rpcSpan struct {
+	trace.Span
+	call  string
+	start time.Time
+}
+
+// startRPC - RPC呼び出し call のスパンを開始する
+func startRPC(ctx context.Context, call string) (context.Context, *rpcSpan) {
+	ctx, span := tracer.Start(ctx, "rpc "+call,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rpc.call", call)))
+	return ctx, &rpcSpan{Span: span, call: call, start: time.Now()}
+}
+
+// end - 所要時間を記録し、エラーがあればスパンに記録して終了する
+func (s *rpcSpan) end(err error) {
+	observeRPC(s.call, s.start)
+	endSpan(s.Span, err)
+}
+
+// traceCarrier - ctx のトレースコンテキスト（traceparent 等）をジョブに保存できる形で返す（無ければnil）
+func traceCarrier(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// startJobSpan - ジョブを作成したリクエストのトレースを親にして、ジョブの処理のスパンを開始する
+func startJobSpan(ctx context.Context, name string, job *MintJob) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(job.TraceContext))
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("mint.job_id", job.ID),
+		attribute.String("mint.wallet", job.WalletAddress)))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestJobSpans - ジョブの処理のスパンが作成したリクエストのトレースに続き、RPCのスパンがその子になること
+func TestJobSpans(t *testing.T) {
+	// 環境変数のサービス名を使わない
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "")
+	ctx := context.Background()
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := newTracerProvider(ctx, exporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { provider.Shutdown(ctx) })
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	// リクエストのスパンの中で作ったジョブはトレースコンテキストを保存する
+	reqCtx, reqSpan := tracer.Start(ctx, "POST /mint")
+	job := &MintJob{
+		ID:            "job-1",
+		WalletAddress: "0x1111111111111111111111111111111111111111",
+		TraceContext:  traceCarrier(reqCtx),
+	}
+	reqSpan.End()
+	if job.TraceContext["traceparent"] == "" {
+		t.Fatalf("job trace context = %v, want traceparent", job.TraceContext)
+	}
+
+	// 非同期の処理はリクエストのcontextを持たない
+	jobCtx, jobSpan := startJobSpan(ctx, "mint.job.send", job)
+	_, rpc := startRPC(jobCtx, rpcCallSend)
+	rpc.end(errors.New("connection refused"))
+	jobSpan.End()
+
+	if err := provider.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+	req, jobStub, rpcStub := spans["POST /mint"], spans["mint.job.send"], spans["rpc send"]
+	if !req.SpanContext.IsValid() || !jobStub.SpanContext.IsValid() || !rpcStub.SpanContext.IsValid() {
+		t.Fatalf("exported spans = %v, want POST /mint, mint.job.send and rpc send", exporter.GetSpans())
+	}
+
+	if jobStub.Parent.SpanID() != req.SpanContext.SpanID() || jobStub.SpanContext.TraceID() != req.SpanContext.TraceID() {
+		t.Errorf("mint.job.send parent = %s, want request span %s", jobStub.Parent.SpanID(), req.SpanContext.SpanID())
+	}
+	if !slices.Contains(jobStub.Attributes, attribute.String("mint.job_id", job.ID)) ||
+		!slices.Contains(jobStub.Attributes, attribute.String("mint.wallet", job.WalletAddress)) {
+		t.Errorf("mint.job.send attributes = %v, want job ID and wallet", jobStub.Attributes)
+	}
+
+	if rpcStub.Parent.SpanID() != jobStub.SpanContext.SpanID() {
+		t.Errorf("rpc send parent = %s, want job span %s", rpcStub.Parent.SpanID(), jobStub.SpanContext.SpanID())
+	}
+	if !slices.Contains(rpcStub.Attributes, attribute.String("rpc.call", rpcCallSend)) {
+		t.Errorf("rpc send attributes = %v, want rpc.call=%s", rpcStub.Attributes, rpcCallSend)
+	}
+	if rpcStub.Status.Code != codes.Error || len(rpcStub.Events) == 0 {
+		t.Errorf("rpc send status = %v with %d events, want recorded error", rpcStub.Status, len(rpcStub.Events))
+	}
+	if res := rpcStub.Resource.Attributes(); !slices.Contains(res, attribute.String("service.name", serviceName)) {
+		t.Errorf("resource = %v, want service.name=%s", res, serviceName)
+	}
+}
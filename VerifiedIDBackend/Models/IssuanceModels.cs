@@ -68,7 +68,13 @@ public class VerificationStatus
     public string RequestId { get; set; } = string.Empty;
     public string Status { get; set; } = "pending"; // pending, verified, failed
     public string? WalletAddress { get; set; }
+    [System.Text.Json.Serialization.JsonIgnore]
+    public string? SiweMessage { get; set; } // Mintサービスに渡すウォレットの所有証明
+    [System.Text.Json.Serialization.JsonIgnore]
+    public string? SiweSignature { get; set; }
     public string? TransactionHash { get; set; }
+    public string? ErrorCode { get; set; } // Mintサービスの errorCode（失敗時）
+    public string? ErrorMessage { get; set; }
     public DateTime CreatedAt { get; set; } = DateTime.UtcNow;
     public DateTime? VerifiedAt { get; set; }
 }
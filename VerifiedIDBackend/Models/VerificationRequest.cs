@@ -3,4 +3,7 @@ namespace VerifiedIDBackend.Models;
 public class VerificationRequest
 {
     public string WalletAddress { get; set; } = string.Empty;
+    // ウォレットの所有証明（Sign-In with Ethereumのメッセージと personal_sign の署名、任意）
+    public string? SiweMessage { get; set; }
+    public string? SiweSignature { get; set; }
 }
@@ -81,7 +81,9 @@ app.MapPost("/api/verify", async (
         {
             RequestId = response.RequestId,
             Status = "pending",
-            WalletAddress = request.WalletAddress
+            WalletAddress = request.WalletAddress,
+            SiweMessage = request.SiweMessage,
+            SiweSignature = request.SiweSignature
         };
 
         // 検証リクエストURLを生成（ディープリンク用）
@@ -187,9 +189,32 @@ app.MapPost("/api/callback", async (CallbackPayload payload, HttpContext httpCon
                 {
                     using var httpClient = new HttpClient();
                     var mintServiceUrl = Environment.GetEnvironmentVariable("MINT_SERVICE_URL") ?? "http://localhost:8080";
+                    var mintServiceApiKey = Environment.GetEnvironmentVariable("MINT_SERVICE_API_KEY");
+                    if (!string.IsNullOrEmpty(mintServiceApiKey))
+                    {
+                        httpClient.DefaultRequestHeaders.Authorization =
+                            new System.Net.Http.Headers.AuthenticationHeaderValue("Bearer", mintServiceApiKey);
+                    }
+                    // Mintサービスのログと突き合わせられるように、Verified IDのリクエストIDを渡す
+                    httpClient.DefaultRequestHeaders.Add("X-Request-ID", payload.RequestId);
+                    // 検証したVerified IDの種類と発行者をアテステーションに含める
+                    var credential = payload.VerifiedCredentialsData?.FirstOrDefault();
+                    // フロントエンドで署名されたウォレットの所有証明があれば転送する
+                    verificationStatuses.TryGetValue(payload.RequestId, out var pending);
+                    var siwe = string.IsNullOrEmpty(pending?.SiweMessage)
+                        ? null
+                        : new { message = pending.SiweMessage, signature = pending.SiweSignature };
                     var mintRequest = new
                     {
-                        walletAddress = payload.State
+                        walletAddress = payload.State,
+                        requestId = payload.RequestId,
+                        wait = true,
+                        attestation = MintAttestation.Create(
+                            payload.RequestId,
+                            payload.State,
+                            credential?.Type?.LastOrDefault(),
+                            credential?.Issuer),
+                        siwe
                     };
                     var jsonContent = System.Text.Json.JsonSerializer.Serialize(mintRequest);
                     var content = new StringContent(jsonContent, System.Text.Encoding.UTF8, "application/json");
@@ -223,12 +248,30 @@ app.MapPost("/api/callback", async (CallbackPayload payload, HttpContext httpCon
                     }
                     else
                     {
-                        app.Logger.LogError("Failed to mint SBT. Status: {StatusCode}", response.StatusCode);
+                        var responseBody = await response.Content.ReadAsStringAsync();
+                        app.Logger.LogError("Failed to mint SBT. Status: {StatusCode}, Response: {Response}",
+                            response.StatusCode, responseBody);
 
-                        // 検証状態を失敗に更新
+                        // 検証状態を失敗に更新（Mintサービスのエラーコードとメッセージを保持）
                         if (verificationStatuses.TryGetValue(payload.RequestId, out var status))
                         {
                             status.Status = "failed";
+                            try
+                            {
+                                var mintResponse = System.Text.Json.JsonSerializer.Deserialize<System.Text.Json.JsonElement>(responseBody);
+                                if (mintResponse.TryGetProperty("errorCode", out var errorCodeElement))
+                                {
+                                    status.ErrorCode = errorCodeElement.GetString();
+                                }
+                                if (mintResponse.TryGetProperty("message", out var messageElement))
+                                {
+                                    status.ErrorMessage = messageElement.GetString();
+                                }
+                            }
+                            catch (Exception parseEx)
+                            {
+                                app.Logger.LogError(parseEx, "Error parsing mint error response");
+                            }
                         }
                     }
                 }
@@ -0,0 +1,55 @@
+using System.Security.Cryptography;
+using System.Text;
+using System.Text.Json;
+
+namespace VerifiedIDBackend.Services;
+
+// Mintサービスに渡すVerified ID検証結果のアテステーション（ES256で署名したJWS）
+public static class MintAttestation
+{
+    // 有効期間（Mintサービスは10分を超えるものを拒否する）
+    private static readonly TimeSpan Lifetime = TimeSpan.FromMinutes(5);
+
+    // MINT_ATTESTATION_PRIVATE_KEY（P-256のPEM）が未設定の場合はnullを返す
+    public static string? Create(string requestId, string walletAddress, string? credentialType, string? credentialIssuer)
+    {
+        var privateKeyPem = Environment.GetEnvironmentVariable("MINT_ATTESTATION_PRIVATE_KEY");
+        if (string.IsNullOrEmpty(privateKeyPem))
+        {
+            return null;
+        }
+        var keyId = Environment.GetEnvironmentVariable("MINT_ATTESTATION_KEY_ID") ?? "backend";
+        var issuer = Environment.GetEnvironmentVariable("MINT_ATTESTATION_ISSUER") ?? "nft-poc-backend";
+
+        using var ecdsa = ECDsa.Create();
+        // 環境変数では改行を \n と書けるようにする
+        ecdsa.ImportFromPem(privateKeyPem.Replace("\\n", "\n"));
+
+        var now = DateTimeOffset.UtcNow;
+        var header = new Dictionary<string, string>
+        {
+            ["alg"] = "ES256",
+            ["typ"] = "JWT",
+            ["kid"] = keyId
+        };
+        var claims = new Dictionary<string, object?>
+        {
+            ["iss"] = issuer,
+            ["requestId"] = requestId,
+            ["wallet"] = walletAddress,
+            ["credentialType"] = credentialType,
+            ["credentialIssuer"] = credentialIssuer,
+            ["iat"] = now.ToUnixTimeSeconds(),
+            ["exp"] = now.Add(Lifetime).ToUnixTimeSeconds()
+        };
+
+        var signingInput = Base64Url(JsonSerializer.SerializeToUtf8Bytes(header)) + "." +
+                           Base64Url(JsonSerializer.SerializeToUtf8Bytes(claims));
+        // SignData は JWS と同じ r || s 形式で署名を返す
+        var signature = ecdsa.SignData(Encoding.ASCII.GetBytes(signingInput), HashAlgorithmName.SHA256);
+        return signingInput + "." + Base64Url(signature);
+    }
+
+    private static string Base64Url(byte[] data) =>
+        Convert.ToBase64String(data).TrimEnd('=').Replace('+', '-').Replace('/', '_');
+}
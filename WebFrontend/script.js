@@ -182,13 +182,17 @@ async function generateVerificationQR() {
     try {
         addActivity('🏢 企業: 検証用QRコードを生成中...');
 
+        // ブラウザウォレットがあれば受取アドレスの所有証明に署名してもらう
+        const siwe = await signWalletOwnership(walletAddress);
+
         const response = await fetch(`${API_BASE_URL}/api/verify`, {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
             },
             body: JSON.stringify({
-                walletAddress: walletAddress
+                walletAddress: walletAddress,
+                ...siwe
             })
         });
 
@@ -230,6 +234,56 @@ async function generateVerificationQR() {
     }
 }
 
+// Sign-In with Ethereum (EIP-4361) でウォレットの所有を証明する
+// ウォレットが無い・署名が拒否された場合は空オブジェクトを返す（Mintサービスが必須にしていなければそのまま進める）
+async function signWalletOwnership(walletAddress) {
+    if (!window.ethereum) {
+        return {};
+    }
+
+    try {
+        const [account] = await window.ethereum.request({ method: 'eth_requestAccounts' });
+        if (!account || account.toLowerCase() !== walletAddress.toLowerCase()) {
+            addActivity('⚠️ 接続中のウォレットが受取アドレスと異なるため、所有証明をスキップしました');
+            return {};
+        }
+
+        const nonceResponse = await fetch(`${MINT_SERVICE_URL}/siwe/nonce`);
+        if (!nonceResponse.ok) {
+            throw new Error('Failed to get SIWE nonce');
+        }
+        const { nonce, chainId, expiresAt } = await nonceResponse.json();
+
+        // EIP-4361では署名するアドレスをEIP-55のチェックサム形式で書くが、ウォレットが返す形式のまま使う
+        // （Mintサービスはアドレスを正規化して比較する）
+        const message = [
+            `${window.location.host} wants you to sign in with your Ethereum account:`,
+            account,
+            '',
+            'SBTの受取アドレスを所有していることを証明します。',
+            '',
+            `URI: ${window.location.origin}`,
+            'Version: 1',
+            `Chain ID: ${chainId}`,
+            `Nonce: ${nonce}`,
+            `Issued At: ${new Date().toISOString()}`,
+            `Expiration Time: ${expiresAt}`
+        ].join('\n');
+
+        const signature = await window.ethereum.request({
+            method: 'personal_sign',
+            params: [message, account]
+        });
+
+        addActivity('🔏 ウォレットの所有証明に署名しました');
+        return { siweMessage: message, siweSignature: signature };
+    } catch (error) {
+        console.warn('⚠️ SIWE signing skipped:', error);
+        addActivity('⚠️ ウォレットの所有証明をスキップしました');
+        return {};
+    }
+}
+
 // Poll verification status
 async function pollVerificationStatus(requestId, walletAddress) {
     const maxAttempts = 60; // 最大5分間（5秒ごと）
@@ -253,6 +307,9 @@ async function pollVerificationStatus(requestId, walletAddress) {
                 return; // ポーリング終了
             } else if (status.status === 'failed') {
                 addActivity(`❌ 検証に失敗しました`);
+                if (status.errorCode) {
+                    addActivity(`⚠️ ${status.errorMessage || 'SBTのミントに失敗しました'} (${status.errorCode})`);
+                }
                 return; // ポーリング終了
             }
 